@@ -0,0 +1,125 @@
+package llmcat
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/everestmz/llmcat/git"
+	"github.com/rs/zerolog/log"
+)
+
+const maxBlameAuthorLength = 16
+
+// blameFile returns the last commit to touch each line of text, which lives at
+// path on disk. If path isn't in a git repo, or blame fails, it returns nil and
+// the file is rendered without history
+func blameFile(path, text string) []*git.BlameLine {
+	if path == "" {
+		return nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		log.Debug().Err(err).Str("path", path).Msg("Unable to blame file")
+		return nil
+	}
+
+	repo, err := git.NewRepo(filepath.Dir(absPath))
+	if err != nil {
+		log.Debug().Err(err).Str("path", path).Msg("Unable to blame file")
+		return nil
+	}
+
+	blame, err := repo.Blame(absPath, text)
+	if err != nil {
+		log.Debug().Err(err).Str("path", path).Msg("Unable to blame file")
+		return nil
+	}
+
+	return blame
+}
+
+// formatBlameColumn returns the short hash, author and age of each line's last
+// commit, as displayed in the gutter
+func formatBlameColumn(blame []*git.BlameLine, now time.Time) []string {
+	column := make([]string, len(blame))
+	for i, line := range blame {
+		if line == nil {
+			column[i] = "(uncommitted)"
+			continue
+		}
+
+		author := line.Author
+		if utf8.RuneCountInString(author) > maxBlameAuthorLength {
+			author = string([]rune(author)[:maxBlameAuthorLength])
+		}
+
+		column[i] = fmt.Sprintf("%s %s %s", line.ShortHash(), author, formatAge(now.Sub(line.Date)))
+	}
+
+	return column
+}
+
+// changedSince returns true if any line from startRow to endRow (0-indexed,
+// inclusive) was last changed after cutoff. Uncommitted lines always count
+func changedSince(blame []*git.BlameLine, startRow, endRow int, cutoff time.Time) bool {
+	for row := max(startRow, 0); row <= endRow && row < len(blame); row++ {
+		if blame[row] == nil || blame[row].Date.After(cutoff) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatAge(age time.Duration) string {
+	const day = 24 * time.Hour
+
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < day:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	case age < 30*day:
+		return fmt.Sprintf("%dd", int(age/day))
+	case age < 365*day:
+		return fmt.Sprintf("%dmo", int(age/(30*day)))
+	default:
+		return fmt.Sprintf("%dy", int(age/(365*day)))
+	}
+}
+
+// ParseAge parses ages like "30d", "2w", "6mo" and "1y", as well as anything
+// time.ParseDuration accepts
+func ParseAge(age string) (time.Duration, error) {
+	const day = 24 * time.Hour
+
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"mo", 30 * day},
+		{"d", day},
+		{"w", 7 * day},
+		{"y", 365 * day},
+	}
+
+	for _, u := range units {
+		if !strings.HasSuffix(age, u.suffix) {
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimSuffix(age, u.suffix))
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q: %w", age, err)
+		}
+
+		return time.Duration(n) * u.unit, nil
+	}
+
+	return time.ParseDuration(age)
+}
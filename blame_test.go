@@ -0,0 +1,189 @@
+package llmcat
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/everestmz/llmcat/git"
+)
+
+func TestParseAge(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		age     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{age: "30d", want: 30 * day},
+		{age: "2w", want: 14 * day},
+		{age: "6mo", want: 180 * day},
+		{age: "1y", want: 365 * day},
+		{age: "90m", want: 90 * time.Minute},
+		{age: "12h", want: 12 * time.Hour},
+		{age: "0d", want: 0},
+		{age: "d", wantErr: true},
+		{age: "1.5d", wantErr: true},
+		{age: "soon", wantErr: true},
+		{age: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.age, func(t *testing.T) {
+			got, err := ParseAge(tt.age)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAge(%q) error = %v, wantErr %v", tt.age, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseAge(%q) = %v, want %v", tt.age, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{age: 0, want: "0m"},
+		{age: 59 * time.Minute, want: "59m"},
+		{age: time.Hour, want: "1h"},
+		{age: 23 * time.Hour, want: "23h"},
+		{age: day, want: "1d"},
+		{age: 29 * day, want: "29d"},
+		{age: 30 * day, want: "1mo"},
+		{age: 364 * day, want: "12mo"},
+		{age: 365 * day, want: "1y"},
+		{age: 3 * 365 * day, want: "3y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := formatAge(tt.age); got != tt.want {
+				t.Errorf("formatAge(%v) = %q, want %q", tt.age, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecentlyChanged(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	dir := t.TempDir()
+	runTestGit(t, dir, "init", "-q")
+
+	path := filepath.Join(dir, "main.go")
+	writeTestFile(t, path, "package main\n\nfunc Old() {\n\tprintln(1)\n}\n\nfunc New() {\n\tprintln(1)\n}\n\nfunc Edited() {\n\tprintln(1)\n}\n")
+	runTestGit(t, dir, "add", "-A")
+	runTestGit(t, dir, "commit", "-q", "-m", "first", "--date", "2020-01-01T00:00:00Z")
+
+	// Committed just now
+	writeTestFile(t, path, "package main\n\nfunc Old() {\n\tprintln(1)\n}\n\nfunc New() {\n\tprintln(2)\n}\n\nfunc Edited() {\n\tprintln(1)\n}\n")
+	runTestGit(t, dir, "commit", "-q", "-am", "second")
+
+	// Not committed at all
+	writeTestFile(t, path, "package main\n\nfunc Old() {\n\tprintln(1)\n}\n\nfunc New() {\n\tprintln(2)\n}\n\nfunc Edited() {\n\tprintln(3)\n}\n")
+
+	age, err := ParseAge("30d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := RenderDirectory(dir, &RenderDirectoryOptions{
+		FileOptions: &RenderFileOptions{
+			Outline:         true,
+			RecentlyChanged: age,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"func New() {\n\tprintln(2)\n}", "func Edited() {\n\tprintln(3)\n}"} {
+		if !strings.Contains(output, s) {
+			t.Errorf("expected recently changed code %q to be expanded, got:\n%s", s, output)
+		}
+	}
+
+	if strings.Contains(output, "println(1)") {
+		t.Errorf("expected Old to be omitted, got:\n%s", output)
+	}
+
+	output, err = RenderDirectory(dir, &RenderDirectoryOptions{
+		FileOptions: &RenderFileOptions{ShowBlame: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(output, "\n")
+	for _, tt := range []struct {
+		code  string
+		blame string
+	}{
+		{code: "\tprintln(1)", blame: "llmcat "},
+		{code: "\tprintln(2)", blame: "llmcat 0m"},
+		{code: "\tprintln(3)", blame: "(uncommitted)"},
+	} {
+		found := false
+		for _, line := range lines {
+			if strings.HasSuffix(line, tt.code) {
+				found = true
+				if !strings.Contains(line, tt.blame) {
+					t.Errorf("expected %q to be blamed on %q, got %q", tt.code, tt.blame, line)
+				}
+				break
+			}
+		}
+
+		if !found {
+			t.Errorf("expected a line ending in %q, got:\n%s", tt.code, output)
+		}
+	}
+}
+
+func TestBlameCommittedFile(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	dir := t.TempDir()
+	runTestGit(t, dir, "init", "-q")
+
+	for _, tt := range []struct {
+		name string
+		text string
+	}{
+		{name: "newline.go", text: "package main\n\nfunc main() {\n\tprintln(1)\n}\n"},
+		{name: "no_newline.go", text: "package main\n\nfunc main() {}"},
+	} {
+		writeTestFile(t, filepath.Join(dir, tt.name), tt.text)
+		runTestGit(t, dir, "add", "-A")
+		runTestGit(t, dir, "commit", "-q", "-m", tt.name)
+
+		repo, err := git.NewRepo(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		blame, err := repo.Blame(filepath.Join(dir, tt.name), tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, line := range blame {
+			if line == nil {
+				t.Errorf("%s: expected line %d of a clean file to have a commit", tt.name, i+1)
+			}
+		}
+	}
+}
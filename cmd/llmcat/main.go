@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/everestmz/llmcat"
 	"github.com/everestmz/llmcat/ctxspec"
+	"github.com/everestmz/llmcat/treesym"
+	"github.com/everestmz/llmcat/treesym/language"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 )
@@ -14,9 +20,11 @@ import (
 func main() {
 	var options llmcat.RenderFileOptions
 	var dirOptions llmcat.RenderDirectoryOptions
+	var watchOptions llmcat.WatchOptions
+	var remoteOptions llmcat.RemoteRepoOptions
 
 	var rootCmd = &cobra.Command{
-		Use:   "llmcat [path]",
+		Use:   "llmcat [path | url[#ref:subdir]]",
 		Short: "Display file contents with optional line numbers and formatting",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -32,12 +40,88 @@ func main() {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
+
+			specFiles, err := cmd.Flags().GetStringArray("spec-file")
+			if err != nil {
+				return err
+			}
+
+			for _, specFile := range specFiles {
+				fileSpec, err := ctxspec.ParseFile(specFile)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				contextSpec = ctxspec.Merge(contextSpec, fileSpec)
+			}
 			dirOptions.ContextSpec = contextSpec
 
 			dirOptions.FileOptions = &options
 
-			if strings.HasSuffix(path, ".git") {
-				output, err := llmcat.RenderGitRepo(path, &dirOptions)
+			strict, err := cmd.Flags().GetBool("strict")
+			if err != nil {
+				return err
+			}
+
+			var warnings int
+			options.OnWarning = func(w llmcat.Warning) {
+				warnings++
+			}
+
+			watch, err := cmd.Flags().GetBool("watch")
+			if err != nil {
+				return err
+			}
+
+			emitSpec, err := cmd.Flags().GetString("emit-spec")
+			if err != nil {
+				return err
+			}
+
+			var rendered []*ctxspec.FileContextSpec
+			if emitSpec != "" {
+				if watch {
+					return fmt.Errorf("--emit-spec can't be used with --watch")
+				}
+
+				// A spec can't say which part of the output was shown, so
+				// replaying it would show more than this run does
+				if dirOptions.Page > 0 || options.Page > 0 || cmd.Flags().Changed("start-line") {
+					return fmt.Errorf("--emit-spec can't be used with --dir-page, --page or --start-line")
+				}
+
+				options.OnRendered = func(spec *ctxspec.FileContextSpec) {
+					rendered = append(rendered, spec)
+				}
+			}
+
+			if watch && dirOptions.Page > 0 {
+				return fmt.Errorf("--dir-page can't be used with --watch")
+			}
+
+			if watch {
+				ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+				defer cancel()
+
+				err := llmcat.WatchDirectory(ctx, path, &dirOptions, &watchOptions, func(output string) error {
+					fmt.Println(output)
+					return nil
+				})
+				if err != nil {
+					return fmt.Errorf("error watching directory (%s): %w", path, err)
+				}
+
+				return nil
+			}
+
+			remote, err := cmd.Flags().GetBool("remote")
+			if err != nil {
+				return err
+			}
+			remote = remote || cmd.Flags().Changed("ref") || cmd.Flags().Changed("subdir")
+
+			if llmcat.IsRemoteRepo(path, remote) {
+				output, err := llmcat.RenderGitRepo(path, &remoteOptions, &dirOptions)
 				if err != nil {
 					return fmt.Errorf("error processing repository: %w", err)
 				}
@@ -67,12 +151,166 @@ func main() {
 				}
 			}
 
+			if emitSpec != "" {
+				err = writeSpec(emitSpec, rendered)
+				if err != nil {
+					return fmt.Errorf("error writing spec: %w", err)
+				}
+			}
+
+			if strict && warnings > 0 {
+				fmt.Fprintf(os.Stderr, "Error: %d warning(s) with --strict\n", warnings)
+				os.Exit(1)
+			}
+
 			return nil
 		},
 	}
 
+	var diffCmd = &cobra.Command{
+		Use:   "diff <revA> <revB> [path]",
+		Short: "Show which symbols were added, removed or changed between two git revisions",
+		Args:  cobra.RangeArgs(2, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "."
+			if len(args) > 2 {
+				path = args[2]
+			}
+
+			output, err := llmcat.RenderGitDiff(path, args[0], args[1], &options)
+			if err != nil {
+				return fmt.Errorf("error rendering diff: %w", err)
+			}
+			fmt.Println(output)
+
+			return nil
+		},
+	}
+	rootCmd.AddCommand(diffCmd)
+
+	var viewOptions llmcat.ViewOptions
+	var viewCmd = &cobra.Command{
+		Use:   "view <file>",
+		Short: "Show the lines around a line or symbol, under the signatures of the definitions they're in",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			content, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("error reading file: %v", err)
+			}
+
+			options.ShowPageInfo = true
+			output, err := llmcat.RenderView(args[0], string(content), &viewOptions, &options)
+			if err != nil {
+				return fmt.Errorf("error rendering view: %w", err)
+			}
+			fmt.Println(output)
+
+			return nil
+		},
+	}
+	viewCmd.Flags().IntVar(&viewOptions.Around, "around", 0, "line to show, with --context lines around it (1-based)")
+	viewCmd.Flags().StringVar(&viewOptions.Symbol, "symbol", "", "symbol to show, by name or qualified name like Server.Handle")
+	viewCmd.Flags().IntVar(&viewOptions.Context, "context", 10, "lines to show before and after the line or symbol")
+	viewCmd.MarkFlagsMutuallyExclusive("around", "symbol")
+	viewCmd.MarkFlagsOneRequired("around", "symbol")
+	rootCmd.AddCommand(viewCmd)
+
+	var fromTraceCmd = &cobra.Command{
+		Use:   "from-trace [path]",
+		Short: "Read a stack trace or compiler errors on stdin, and show the functions they point to in the outline",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "."
+			if len(args) > 0 {
+				path = args[0]
+			}
+
+			locations, err := llmcat.ParseTrace(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("error reading trace: %w", err)
+			}
+
+			dirOptions.FileOptions = &options
+			output, err := llmcat.RenderTrace(path, locations, &dirOptions)
+			if err != nil {
+				return fmt.Errorf("error rendering trace: %w", err)
+			}
+			fmt.Println(output)
+
+			return nil
+		},
+	}
+	rootCmd.AddCommand(fromTraceCmd)
+
+	var grepOptions llmcat.GrepOptions
+	var grepCmd = &cobra.Command{
+		Use:   "grep <pattern> [path]",
+		Short: "Search files with a regex, showing each match under the signatures of the definitions it's in",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "."
+			if len(args) > 1 {
+				path = args[1]
+			}
+
+			grepOptions.Pattern = args[0]
+			dirOptions.FileOptions = &options
+			output, err := llmcat.RenderGrep(path, &grepOptions, &dirOptions)
+			if err != nil {
+				return fmt.Errorf("error searching: %w", err)
+			}
+
+			if output == "" {
+				// Like grep, nothing matching is a failure
+				os.Exit(1)
+			}
+			fmt.Println(output)
+
+			return nil
+		},
+	}
+	grepCmd.Flags().BoolVarP(&grepOptions.IgnoreCase, "ignore-case", "i", false, "match the pattern regardless of case")
+	grepCmd.Flags().IntVarP(&grepOptions.Context, "context", "C", 2, "lines to show before and after each match, within the definition it's in")
+	rootCmd.AddCommand(grepCmd)
+
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		recent, err := cmd.Flags().GetString("recent")
+		if err != nil {
+			return err
+		}
+
+		if recent != "" {
+			options.RecentlyChanged, err = llmcat.ParseAge(recent)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Catch unknown languages and broken queries before rendering anything
+		_, err = language.NewDetector(options.Languages)
+		if err != nil {
+			return err
+		}
+
+		queriesDir, err := cmd.Flags().GetString("queries-dir")
+		if err != nil {
+			return err
+		}
+
+		if queriesDir != "" {
+			err = treesym.LoadQueries(queriesDir)
+			if err != nil {
+				return fmt.Errorf("loading queries: %w", err)
+			}
+		}
+
+		return nil
+	}
+
 	// Add flags
 	flags := rootCmd.Flags()
+	fileFlags := rootCmd.PersistentFlags()
 
 	// Dev flags
 	debug := flags.Bool("debug", false, "Set this flag to enable debug logs")
@@ -81,28 +319,76 @@ func main() {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
 
-	// File rendering flags
-	flags.BoolVarP(&options.OutputMarkdown, "markdown", "m", true, "output in markdown format")
-	flags.BoolVarP(&options.ShowLineNumbers, "line-numbers", "n", true, "show line numbers")
-	flags.StringVarP(&options.GutterSeparator, "separator", "s", "|", "gutter separator character")
+	// File rendering flags, shared with subcommands
+	fileFlags.BoolVarP(&options.OutputMarkdown, "markdown", "m", true, "output in markdown format")
+	fileFlags.BoolVarP(&options.ShowLineNumbers, "line-numbers", "n", true, "show line numbers")
+	fileFlags.StringVarP(&options.GutterSeparator, "separator", "s", "|", "gutter separator character")
 	flags.BoolVar(&options.Outline, "outline", false, "produce an outline for supported source files using tree-sitter")
+	flags.BoolVar(&options.Tree, "tree", false, "only list the selected files and how many lines they have")
+	fileFlags.StringVar(&options.OmittedTemplate, "omitted-template", llmcat.DefaultOmittedTemplate, "Go template for the placeholder that replaces omitted symbols, given .Lines, .Kind, .Name, .Path and .Expand")
+	flags.IntVar(&options.OutlineDepth, "outline-depth", 2, "levels of keys to keep when outlining data files like YAML, JSON and TOML")
+	fileFlags.String("queries-dir", os.Getenv("LLMCAT_QUERIES_DIR"), "directory of <language>.scm tags queries to use instead of the built in ones, or to extend them if they start with \"; extends\" (defaults to $LLMCAT_QUERIES_DIR)")
+	fileFlags.StringToStringVar(&options.Languages, "lang-map", nil, "parse files with an extension or name as a language, like .tpl=go or Justfile=bash")
 	flags.StringArrayVar(&options.ExpandSymbols, "symbols", nil, "specify symbols to expand when showing an outline")
 
+	// History flags
+	fileFlags.BoolVar(&options.ShowBlame, "blame", false, "show the hash, author and age of the last commit to touch each line in the gutter")
+	fileFlags.IntVar(&options.History, "history", 0, "append the last N commits to touch each file after it")
+	fileFlags.BoolVar(&options.ShowHistoryDiff, "history-diff", false, "with --history, also append the diff of the most recent commit to each file")
+	fileFlags.String("recent", "", "when showing an outline, expand symbols changed within this age (e.g. 30d, 2w, 6mo)")
+
 	// Pagination flags
 	flags.IntVarP(&options.PageSize, "page-size", "p", 10000, "number of lines to show (0 = show all)")
 	flags.IntVar(&options.StartLine, "start-line", 1, "first line to show (1-based)")
+	flags.IntVar(&options.Page, "page", 0, "page of --page-size lines to show (1-based) instead of --start-line, moving page breaks so that no top-level definition is split")
 	flags.BoolVar(&options.ShowPageInfo, "show-page-info", true, "show page information in header")
 
-	// Directory flags
-	flags.StringSliceVar(&dirOptions.IgnoreGlobs, "ignore", []string{"**/.git/**"}, "glob patterns to ignore")
-	flags.StringSliceVar(&dirOptions.IncludeGlobs, "include", nil, "glob patterns to include")
-	flags.StringSliceVar(&dirOptions.ExcludeExtensions, "exclude-ext", nil, "comma-separated list of file extensions to exclude")
-	flags.StringSliceVar(&dirOptions.IncludeExtensions, "ext", nil, "comma-separated list of file extensions to include")
+	// Directory flags, with the filters shared with subcommands
+	fileFlags.StringSliceVar(&dirOptions.IgnoreGlobs, "ignore", []string{"**/.git/**"}, "glob patterns to ignore")
+	fileFlags.StringSliceVar(&dirOptions.IncludeGlobs, "include", nil, "glob patterns to include")
+	fileFlags.StringSliceVar(&dirOptions.ExcludeExtensions, "exclude-ext", nil, "comma-separated list of file extensions to exclude")
+	fileFlags.StringSliceVar(&dirOptions.IncludeExtensions, "ext", nil, "comma-separated list of file extensions to include")
+	flags.IntVar(&dirOptions.Page, "dir-page", 0, "split a directory's output into pages and show this one (1-based), with the files and lines it covers")
+	flags.IntVar(&dirOptions.PageLines, "dir-page-lines", 0, fmt.Sprintf("most lines on each --dir-page (defaults to %d if neither limit is set)", llmcat.DefaultPageLines))
+	flags.IntVar(&dirOptions.PageTokens, "dir-page-tokens", 0, "most tokens on each --dir-page, estimated at four characters a token")
 
 	flags.StringSliceP("expand", "e", nil, "symbols or files to expand when showing an outline, in ctxspec format")
+	flags.StringVar((*string)(&dirOptions.SpecMode), "spec-mode", string(llmcat.SpecOnly), "with a ctxspec, render only its files (only), or every file with the spec expanded on top of the outline (overlay)")
+	flags.StringArray("spec-file", nil, "file of ctxspec lines to render, with # comments, globs, !negations and @include lines (- for stdin)")
+	flags.String("emit-spec", "", "write the ctxspec that renders the same files and expands the same symbols to this file, to replay with --spec-file")
+	flags.Bool("strict", false, "exit with an error after rendering if there were any warnings, like symbols or files in --expand that couldn't be found")
+
+	// Remote repo flags
+	flags.Bool("remote", false, "treat a path like owner/repo that doesn't exist locally as a GitHub repo")
+	flags.StringVar(&remoteOptions.Ref, "ref", "", "branch, tag or full commit hash to render when the path is a git remote")
+	flags.StringVar(&remoteOptions.Subdir, "subdir", "", "subdirectory to check out and render when the path is a git remote")
+	flags.StringVar(&remoteOptions.AuthToken, "auth-token", os.Getenv("LLMCAT_GIT_TOKEN"), "token for cloning private https remotes (defaults to $LLMCAT_GIT_TOKEN)")
+	flags.BoolVar(&remoteOptions.Cache, "cache", true, "keep a blobless local mirror of git remotes and render from it, rather than doing a sparse clone every time")
+	flags.StringVar(&remoteOptions.CacheDir, "cache-dir", "", "directory for cached mirrors (defaults to the user cache directory)")
+	flags.DurationVar(&remoteOptions.CacheTTL, "cache-ttl", time.Hour, "how long to use a cached mirror before fetching it again")
+	flags.BoolVar(&remoteOptions.Refresh, "refresh", false, "fetch the cached mirror even if it's younger than --cache-ttl")
+
+	// Watch flags
+	flags.Bool("watch", false, "keep running and re-render the directory whenever a selected file changes")
+	flags.BoolVar(&watchOptions.Diff, "watch-diff", false, "when watching, print a diff of the rendered output instead of a full refresh")
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// writeSpec writes one ctxspec line for each rendered file to path, sorted by
+// file name
+func writeSpec(path string, specs []*ctxspec.FileContextSpec) error {
+	slices.SortFunc(specs, func(a, b *ctxspec.FileContextSpec) int {
+		return strings.Compare(a.Filename, b.Filename)
+	})
+
+	var spec strings.Builder
+	for _, fileSpec := range specs {
+		spec.WriteString(fileSpec.String() + "\n")
+	}
+
+	return os.WriteFile(path, []byte(spec.String()), 0644)
+}
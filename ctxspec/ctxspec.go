@@ -1,8 +1,9 @@
 package ctxspec
 
 import (
-	"bufio"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 )
 
@@ -13,10 +14,24 @@ type FileContextSpec struct {
 	Symbols  []string
 }
 
+// OutlineOnly is a symbol that selects a file without expanding anything in
+// it, so that it's outlined rather than shown in full
+const OutlineOnly = "-"
+
+// ExpandedSymbols is Symbols without OutlineOnly
+func (fcs *FileContextSpec) ExpandedSymbols() []string {
+	return slices.DeleteFunc(slices.Clone(fcs.Symbols), func(symbol string) bool {
+		return symbol == OutlineOnly
+	})
+}
+
 func MergeContextSpecs(specs ...*FileContextSpec) ContextSpec {
 	filenameToSpec := map[string]*FileContextSpec{}
 
 	for _, spec := range specs {
+		// Merging appends to Symbols, which mustn't change the caller's specs
+		spec = &FileContextSpec{Filename: spec.Filename, Symbols: slices.Clone(spec.Symbols)}
+
 		if len(spec.Symbols) == 0 {
 			// Just specifying the file
 			filenameToSpec[spec.Filename] = spec
@@ -37,20 +52,24 @@ func MergeContextSpecs(specs ...*FileContextSpec) ContextSpec {
 	return filenameToSpec
 }
 
-func ParseContextSpec(contextDefinition string) (ContextSpec, error) {
-	scanner := bufio.NewScanner(strings.NewReader(contextDefinition))
-	scanner.Split(bufio.ScanLines)
-
+// Merge combines specs as if all of their lines were in one
+func Merge(specs ...ContextSpec) ContextSpec {
 	var items []*FileContextSpec
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		newItem, err := ParseSpecLine(line)
-		if err != nil {
-			return nil, fmt.Errorf("Error for line '%s': %w", line, err)
+	for _, spec := range specs {
+		for _, filename := range slices.Sorted(maps.Keys(spec)) {
+			items = append(items, spec[filename])
 		}
+	}
+
+	return MergeContextSpecs(items...)
+}
 
-		items = append(items, newItem)
+// ParseContextSpec parses spec lines, along with the comments and includes
+// that ParseFile allows. Includes are relative to the working directory
+func ParseContextSpec(contextDefinition string) (ContextSpec, error) {
+	items, err := parseLines(strings.NewReader(contextDefinition), "", ".", map[string]bool{})
+	if err != nil {
+		return nil, err
 	}
 
 	return MergeContextSpecs(items...), nil
@@ -85,6 +104,26 @@ func ParseSpecLine(line string) (*FileContextSpec, error) {
 	return contextItem, nil
 }
 
+// String formats the spec as a line that ParseSpecLine can read, quoting any
+// items that need it
+func (fcs *FileContextSpec) String() string {
+	parts := []string{quotePart(fcs.Filename)}
+	for _, symbol := range fcs.Symbols {
+		parts = append(parts, quotePart(symbol))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func quotePart(part string) string {
+	if part != "" && !strings.ContainsAny(part, " \t\"\\") {
+		return part
+	}
+
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(part)
+	return `"` + escaped + `"`
+}
+
 func getLineParts(line string) ([]string, error) {
 	var parts []string
 
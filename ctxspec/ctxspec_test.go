@@ -33,6 +33,16 @@ func TestParseContextSpec(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:  "qualified symbols",
+			input: `Greeter.java Greeter.greet#2 Buffer.String`,
+			want: ContextSpec{
+				"Greeter.java": {
+					Filename: "Greeter.java",
+					Symbols:  []string{"Greeter.greet#2", "Buffer.String"},
+				},
+			},
+		},
 		{
 			name: "multiple files with symbols",
 			input: `main.go MyFunc
@@ -213,6 +223,24 @@ func TestMergeContextSpecs(t *testing.T) {
 	}
 }
 
+func TestMergeDoesNotModifyInputs(t *testing.T) {
+	a := ContextSpec{"main.go": {Filename: "main.go", Symbols: []string{"Func1"}}}
+	b := ContextSpec{"main.go": {Filename: "main.go", Symbols: []string{"Func2"}}}
+
+	merged := Merge(a, b)
+	if got := merged["main.go"].Symbols; !slicesEqual(got, []string{"Func1", "Func2"}) {
+		t.Fatalf("Merge() symbols = %v, want [Func1 Func2]", got)
+	}
+
+	if got := a["main.go"].Symbols; !slicesEqual(got, []string{"Func1"}) {
+		t.Errorf("Merge() changed its first input's symbols to %v", got)
+	}
+
+	if got := b["main.go"].Symbols; !slicesEqual(got, []string{"Func2"}) {
+		t.Errorf("Merge() changed its second input's symbols to %v", got)
+	}
+}
+
 // Helper function to compare slices
 func slicesEqual(a, b []string) bool {
 	if len(a) != len(b) {
@@ -225,3 +253,25 @@ func slicesEqual(a, b []string) bool {
 	}
 	return true
 }
+
+func TestFileContextSpecString(t *testing.T) {
+	specs := []*FileContextSpec{
+		{Filename: "main.go"},
+		{Filename: "main.go", Symbols: []string{"Func1", "Func2"}},
+		{Filename: "my file.go", Symbols: []string{"Getting Started"}},
+		{Filename: `C:\src\main.go`, Symbols: []string{`Method "quoted" name`}},
+	}
+
+	for _, spec := range specs {
+		line := spec.String()
+
+		got, err := ParseSpecLine(line)
+		if err != nil {
+			t.Fatalf("ParseSpecLine(%q) error = %v", line, err)
+		}
+
+		if got.Filename != spec.Filename || !slicesEqual(got.Symbols, spec.Symbols) {
+			t.Errorf("%q parsed as %+v, want %+v", line, got, spec)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package ctxspec
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ParseFile reads a context spec from the file at path, or from stdin if path
+// is "-". As well as spec lines, it can have blank lines, comments on lines
+// starting with #, and "@include other.spec" lines, which are relative to the
+// file they're in. Errors include the file and line they're on
+func ParseFile(path string) (ContextSpec, error) {
+	items, err := parseFile(path, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	return MergeContextSpecs(items...), nil
+}
+
+// parseFile parses the spec file at path. including has every file that's
+// currently being parsed, to catch include cycles
+func parseFile(path string, including map[string]bool) ([]*FileContextSpec, error) {
+	if path == "-" {
+		return parseLines(os.Stdin, "<stdin>", ".", including)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if including[absPath] {
+		return nil, fmt.Errorf("%s includes itself", path)
+	}
+	including[absPath] = true
+	defer delete(including, absPath)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseLines(f, path, filepath.Dir(path), including)
+}
+
+// parseLines parses the spec in r, which came from name and is resolved
+// relative to dir. name is empty for specs that aren't from a file
+func parseLines(r io.Reader, name, dir string, including map[string]bool) ([]*FileContextSpec, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+
+	var items []*FileContextSpec
+
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+
+		lineErr := func(err error) error {
+			if name == "" {
+				return fmt.Errorf("Error for line '%s': %w", line, err)
+			}
+
+			return fmt.Errorf("%s:%d: %w", name, lineNum, err)
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if include, ok := strings.CutPrefix(line, "@include"); ok && (include == "" || strings.HasPrefix(include, " ") || strings.HasPrefix(include, "\t")) {
+			parts, err := getLineParts(strings.TrimSpace(include))
+			if err != nil {
+				return nil, lineErr(err)
+			}
+
+			if len(parts) != 1 {
+				return nil, lineErr(fmt.Errorf("@include needs exactly one file, got %d", len(parts)))
+			}
+
+			path := parts[0]
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+
+			included, err := parseFile(path, including)
+			if err != nil {
+				return nil, lineErr(err)
+			}
+
+			items = append(items, included...)
+			continue
+		}
+
+		newItem, err := ParseSpecLine(line)
+		if err != nil {
+			return nil, lineErr(err)
+		}
+
+		err = newItem.validate()
+		if err != nil {
+			return nil, lineErr(err)
+		}
+
+		items = append(items, newItem)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
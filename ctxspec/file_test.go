@@ -0,0 +1,167 @@
+package ctxspec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSpec(t *testing.T, path, text string) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(path, []byte(text), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	dir := t.TempDir()
+	writeSpec(t, filepath.Join(dir, "main.spec"), `# What the handlers need
+internal/**/*.go Handler
+!vendor/**
+
+  @include specs/shared.spec
+main.go Main
+`)
+	writeSpec(t, filepath.Join(dir, "specs", "shared.spec"), `# Relative to this file
+main.go Run
+config.go
+`)
+
+	got, err := ParseFile(filepath.Join(dir, "main.spec"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := ContextSpec{
+		"internal/**/*.go": {Filename: "internal/**/*.go", Symbols: []string{"Handler"}},
+		"!vendor/**":       {Filename: "!vendor/**"},
+		"main.go":          {Filename: "main.go", Symbols: []string{"Run", "Main"}},
+		"config.go":        {Filename: "config.go"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d items, want %d", len(got), len(want))
+	}
+	for filename, wantSpec := range want {
+		gotSpec, ok := got[filename]
+		if !ok || !slicesEqual(gotSpec.Symbols, wantSpec.Symbols) {
+			t.Errorf("got %+v for %s, want %+v", gotSpec, filename, wantSpec)
+		}
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeSpec(t, filepath.Join(dir, "quote.spec"), "# fine\nmain.go\nmain.go \"unclosed\n")
+	writeSpec(t, filepath.Join(dir, "negation.spec"), "!vendor/** Func\n")
+	writeSpec(t, filepath.Join(dir, "glob.spec"), "internal/[*.go\n")
+	writeSpec(t, filepath.Join(dir, "cycle.spec"), "main.go\n@include cycle.spec\n")
+	writeSpec(t, filepath.Join(dir, "missing.spec"), "@include nope.spec\n")
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{file: "quote.spec", want: "quote.spec:3: "},
+		{file: "negation.spec", want: "negation.spec:1: negated pattern !vendor/** can't have symbols"},
+		{file: "glob.spec", want: "glob.spec:1: invalid pattern internal/[*.go"},
+		{file: "cycle.spec", want: "cycle.spec:2: " + filepath.Join(dir, "cycle.spec") + " includes itself"},
+		{file: "missing.spec", want: "missing.spec:1: open "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			_, err := ParseFile(filepath.Join(dir, tt.file))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error to contain %q, got %q", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestContextSpecMatch(t *testing.T) {
+	spec, err := ParseContextSpec(`internal/**/*.go Handler
+internal/api/*.go Route
+internal/api/server.go
+cmd/*/main.go
+!internal/**/*_test.go
+!cmd/legacy/**`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		ok      bool
+		symbols []string
+	}{
+		{path: "internal/db/conn.go", ok: true, symbols: []string{"Handler"}},
+		{path: "internal/api/routes.go", ok: true, symbols: []string{"Handler", "Route"}},
+		{path: "internal/api/server.go", ok: true},
+		{path: "internal/api/server_test.go"},
+		{path: "cmd/tool/main.go", ok: true},
+		{path: "cmd/legacy/main.go"},
+		{path: "main.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := spec.Match(tt.path)
+			if ok != tt.ok {
+				t.Fatalf("Match() ok = %v, want %v", ok, tt.ok)
+			}
+
+			if ok && !slicesEqual(got.Symbols, tt.symbols) {
+				t.Errorf("Match() symbols = %v, want %v", got.Symbols, tt.symbols)
+			}
+		})
+	}
+}
+
+func TestMatchesPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{pattern: "internal/**/*.go", path: "internal/handler.go", want: true},
+		{pattern: "internal/**/*.go", path: "internal/api/handler.go", want: true},
+		{pattern: "internal/**/*.go", path: "internal/api/v1/handler.go", want: true},
+		{pattern: "internal/**/*.go", path: "cmd/handler.go"},
+		{pattern: "internal/**/*.go", path: "internal/README.md"},
+		{pattern: "!vendor/**", path: "vendor/lib/lib.go", want: true},
+		{pattern: "!vendor/**", path: "vendor.go"},
+		{pattern: "**/*_test.go", path: "main_test.go", want: true},
+		{pattern: "**/*_test.go", path: "internal/api/server_test.go", want: true},
+		{pattern: "a/**/b/**/c.go", path: "a/b/c.go", want: true},
+		{pattern: "a/**/b/**/c.go", path: "a/x/b/y/z/c.go", want: true},
+		{pattern: "a/**/b/**/c.go", path: "a/c.go"},
+		{pattern: "cmd/*/main.go", path: "cmd/tool/main.go", want: true},
+		{pattern: "cmd/*/main.go", path: "cmd/main.go"},
+		{pattern: "cmd/*/main.go", path: "cmd/a/b/main.go"},
+		{pattern: "docs/*.md", path: "docs/a.md", want: true},
+		{pattern: "main.go", path: "main.go", want: true},
+		{pattern: "main.go", path: "cmd/main.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			spec := &FileContextSpec{Filename: tt.pattern}
+			if got := spec.MatchesPath(tt.path); got != tt.want {
+				t.Errorf("MatchesPath(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package ctxspec
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// IsNegated reports whether the line excludes files rather than selecting
+// them, like !vendor/**
+func (fcs *FileContextSpec) IsNegated() bool {
+	return strings.HasPrefix(fcs.Filename, "!")
+}
+
+// IsPattern reports whether the line is a glob like internal/**/*.go, or a
+// negation, rather than a single file
+func (fcs *FileContextSpec) IsPattern() bool {
+	return fcs.IsNegated() || strings.ContainsAny(fcs.Filename, "*?[{")
+}
+
+// anyGlob matches a path if any of its globs do
+type anyGlob []glob.Glob
+
+func (ag anyGlob) Match(path string) bool {
+	return slices.ContainsFunc(ag, func(g glob.Glob) bool {
+		return g.Match(path)
+	})
+}
+
+// compile builds a matcher for the pattern. A ** directory matches zero or
+// more directories, like it does in .gitignore, so internal/**/*.go matches
+// internal/handler.go too. The glob library needs at least one, so every
+// combination with and without each **/ is compiled
+func (fcs *FileContextSpec) compile() (glob.Glob, error) {
+	var matcher anyGlob
+	for _, pattern := range globstarVariants(strings.TrimPrefix(fcs.Filename, "!")) {
+		g, err := glob.Compile(pattern, '/')
+		if err != nil {
+			return nil, err
+		}
+		matcher = append(matcher, g)
+	}
+
+	return matcher, nil
+}
+
+// globstarVariants returns pattern along with every version of it with some
+// of its **/ directories removed
+func globstarVariants(pattern string) []string {
+	before, after, found := strings.Cut(pattern, "**/")
+	if !found {
+		return []string{pattern}
+	}
+
+	// Only a whole ** directory can be empty, not one ending a name like a**/
+	wholeDir := before == "" || strings.HasSuffix(before, "/")
+
+	var variants []string
+	for _, rest := range globstarVariants(after) {
+		variants = append(variants, before+"**/"+rest)
+		if wholeDir {
+			variants = append(variants, before+rest)
+		}
+	}
+
+	return variants
+}
+
+func (fcs *FileContextSpec) validate() error {
+	if !fcs.IsPattern() {
+		return nil
+	}
+
+	if fcs.IsNegated() && len(fcs.Symbols) > 0 {
+		return fmt.Errorf("negated pattern %s can't have symbols", fcs.Filename)
+	}
+
+	_, err := fcs.compile()
+	if err != nil {
+		return fmt.Errorf("invalid pattern %s: %w", fcs.Filename, err)
+	}
+
+	return nil
+}
+
+// MatchesPath reports whether the line applies to the file at path. Patterns
+// use / as the separator on every platform, and ** matches across directories
+func (fcs *FileContextSpec) MatchesPath(path string) bool {
+	path = filepath.ToSlash(path)
+	if !fcs.IsPattern() {
+		return fcs.Filename == path
+	}
+
+	g, err := fcs.compile()
+	if err != nil {
+		return false
+	}
+
+	return g.Match(path)
+}
+
+// Files returns the single files in the spec, in order
+func (cs ContextSpec) Files() []string {
+	var files []string
+	for filename, spec := range cs {
+		if !spec.IsPattern() {
+			files = append(files, filename)
+		}
+	}
+	slices.Sort(files)
+
+	return files
+}
+
+// Patterns returns the globs and negations in the spec, in order
+func (cs ContextSpec) Patterns() []*FileContextSpec {
+	var patterns []*FileContextSpec
+	for _, spec := range cs {
+		if spec.IsPattern() {
+			patterns = append(patterns, spec)
+		}
+	}
+	slices.SortFunc(patterns, func(a, b *FileContextSpec) int {
+		return strings.Compare(a.Filename, b.Filename)
+	})
+
+	return patterns
+}
+
+// Excludes reports whether a negation in the spec matches the file at path
+func (cs ContextSpec) Excludes(path string) bool {
+	for _, pattern := range cs.Patterns() {
+		if pattern.IsNegated() && pattern.MatchesPath(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match returns what the spec selects from the file at path, and whether it
+// selects it at all. Files named on their own take priority over patterns, and
+// a file matching several patterns gets all of their symbols, or the whole
+// file if any of them selects it. Negations exclude a file however it's selected
+func (cs ContextSpec) Match(path string) (*FileContextSpec, bool) {
+	if cs.Excludes(path) {
+		return nil, false
+	}
+
+	if spec, ok := cs[filepath.ToSlash(path)]; ok && !spec.IsPattern() {
+		return spec, true
+	}
+
+	var matched *FileContextSpec
+	for _, pattern := range cs.Patterns() {
+		if pattern.IsNegated() || !pattern.MatchesPath(path) {
+			continue
+		}
+
+		if matched == nil {
+			matched = &FileContextSpec{Filename: filepath.ToSlash(path), Symbols: slices.Clone(pattern.Symbols)}
+		} else if len(matched.Symbols) > 0 && len(pattern.Symbols) > 0 {
+			matched.Symbols = append(matched.Symbols, pattern.Symbols...)
+		} else {
+			matched.Symbols = nil
+		}
+	}
+
+	return matched, matched != nil
+}
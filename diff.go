@@ -0,0 +1,133 @@
+package llmcat
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/everestmz/llmcat/git"
+	"github.com/everestmz/llmcat/treesym"
+	"github.com/everestmz/llmcat/treesym/language"
+)
+
+var symbolChangeMarkers = map[treesym.SymbolChangeType]string{
+	treesym.SymbolAdded:   "+",
+	treesym.SymbolRemoved: "-",
+	treesym.SymbolChanged: "~",
+}
+
+// RenderGitDiff renders every file under path that changed between fromRev and
+// toRev in the git repo containing path. Each file lists the symbols that were
+// added, removed or changed, followed by the file at toRev as an outline with
+// the added and changed symbols expanded. Renamed files are compared with
+// their old path, so only the symbols that changed with the move are listed
+func RenderGitDiff(path, fromRev, toRev string, options *RenderFileOptions) (string, error) {
+	options.SetDefaults()
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	repo, err := git.NewRepo(path)
+	if err != nil {
+		return "", err
+	}
+
+	changes, err := repo.DiffFiles(fromRev, toRev, path)
+	if err != nil {
+		return "", err
+	}
+
+	var files []string
+	for _, change := range changes {
+		rendered, err := renderFileChange(change, options)
+		if err != nil {
+			return "", fmt.Errorf("error rendering diff for %s: %w", change.Path, err)
+		}
+
+		files = append(files, rendered)
+	}
+
+	return strings.Join(files, "\n\n"), nil
+}
+
+func renderFileChange(change *git.FileChange, options *RenderFileOptions) (string, error) {
+	header := fmt.Sprintf("%s (%s)", change.Path, change.Type)
+	if change.Type == git.ChangeRenamed {
+		header = fmt.Sprintf("%s (renamed from %s)", change.Path, change.OldPath)
+	}
+
+	if change.Binary {
+		return header + ": binary file", nil
+	}
+
+	if change.Type == git.ChangeDeleted {
+		return header, nil
+	}
+
+	detector, err := language.NewDetector(options.Languages)
+	if err != nil {
+		return "", err
+	}
+
+	var after *treesym.ProcessedSourceFile
+	lang, err := detector.Detect(change.Path, change.After)
+	if err == nil {
+		after, err = treesym.GetSymbols(context.TODO(), &treesym.SourceFile{
+			Path:     change.Path,
+			Text:     change.After,
+			Language: lang,
+		})
+	}
+	var before *treesym.ProcessedSourceFile
+	if err == nil && change.Type != git.ChangeAdded {
+		before, err = treesym.GetSymbols(context.TODO(), &treesym.SourceFile{
+			Path:     change.Path,
+			Text:     change.Before,
+			Language: lang,
+		})
+	}
+
+	if err != nil {
+		// We can't say anything about symbols, so fall back to a line diff
+		diff := DiffLines(change.Before, change.After, 3)
+		if options.OutputMarkdown {
+			diff = fmt.Sprintf("```diff\n%s\n```", diff)
+		}
+
+		if !language.IsUnsupported(err) {
+			header += fmt.Sprintf(" (couldn't compare symbols: %v)", err)
+		}
+
+		return header + "\n" + diff, nil
+	}
+
+	symbolChanges := treesym.DiffSymbols(before, after)
+
+	counts := map[treesym.SymbolChangeType]int{}
+	var summaryLines []string
+	var expandSymbols []string
+	for _, symbolChange := range symbolChanges {
+		counts[symbolChange.Type]++
+		summaryLines = append(summaryLines, fmt.Sprintf("%s %s %s", symbolChangeMarkers[symbolChange.Type], symbolChange.Kind, symbolChange.Name))
+
+		if symbolChange.Type != treesym.SymbolRemoved {
+			expandSymbols = append(expandSymbols, symbolChange.After.QualifiedName)
+		}
+	}
+
+	header += fmt.Sprintf(": %d added, %d changed, %d removed", counts[treesym.SymbolAdded], counts[treesym.SymbolChanged], counts[treesym.SymbolRemoved])
+
+	plan := options.Plan()
+	plan.Mode = RenderOutline
+	plan.Expand = expandSymbols
+
+	rendered, err := RenderFilePlan(change.Path, change.After, plan)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(append(append([]string{header}, summaryLines...), rendered), "\n"), nil
+}
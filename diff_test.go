@@ -0,0 +1,75 @@
+package llmcat
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderGitDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	dir := t.TempDir()
+	runTestGit(t, dir, "init", "-q")
+
+	// Long enough that a small change still counts as a rename
+	var util strings.Builder
+	util.WriteString("package main\n")
+	for _, name := range []string{"One", "Two", "Three", "Four"} {
+		util.WriteString("\nfunc " + name + "() {\n\tprintln(\"" + name + "\")\n}\n")
+	}
+
+	writeTestFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc Keep() {\n\tprintln(1)\n}\n\nfunc Change() {\n\tprintln(1)\n}\n\nfunc Remove() {\n\tprintln(1)\n}\n")
+	writeTestFile(t, filepath.Join(dir, "old.go"), util.String())
+	writeTestFile(t, filepath.Join(dir, "gone.go"), "package main\n")
+	runTestGit(t, dir, "add", "-A")
+	runTestGit(t, dir, "commit", "-q", "-m", "first")
+
+	writeTestFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc Keep() {\n\tprintln(1)\n}\n\nfunc Change() {\n\tprintln(2)\n}\n\nfunc Add() {\n\tprintln(2)\n}\n")
+	runTestGit(t, dir, "mv", "old.go", "renamed.go")
+	writeTestFile(t, filepath.Join(dir, "renamed.go"), strings.Replace(util.String(), "println(\"Four\")", "println(4)", 1))
+	runTestGit(t, dir, "rm", "-q", "gone.go")
+	runTestGit(t, dir, "add", "-A")
+	runTestGit(t, dir, "commit", "-q", "-m", "second")
+
+	output, err := RenderGitDiff(dir, "HEAD~1", "HEAD", &RenderFileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		"main.go (modified): 1 added, 1 changed, 1 removed\n~ function Change\n+ function Add\n- function Remove",
+		"func Change() {\n\tprintln(2)\n}",
+		"func Add() {\n\tprintln(2)\n}",
+		"renamed.go (renamed from old.go): 0 added, 1 changed, 0 removed\n~ function Four",
+		"func Four() {\n\tprintln(4)\n}",
+		"gone.go (deleted)",
+	} {
+		if !strings.Contains(output, s) {
+			t.Errorf("expected output to contain %q, got:\n%s", s, output)
+		}
+	}
+
+	for _, s := range []string{"println(\"One\")", "old.go (deleted)", "renamed.go (added)"} {
+		if strings.Contains(output, s) {
+			t.Errorf("expected output not to contain %q, got:\n%s", s, output)
+		}
+	}
+
+	// Keep is unchanged, so it's outlined
+	if strings.Contains(output, "func Keep() {\n\tprintln(1)") {
+		t.Errorf("expected Keep to be outlined, got:\n%s", output)
+	}
+
+	output, err = RenderGitDiff(filepath.Join(dir, "main.go"), "HEAD~1", "HEAD", &RenderFileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(output, "main.go (modified)") || strings.Contains(output, "gone.go") || strings.Contains(output, "renamed.go") {
+		t.Errorf("expected only the changes under the path, got:\n%s", output)
+	}
+}
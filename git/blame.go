@@ -0,0 +1,96 @@
+package git
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// BlameLine is the last commit that modified a line
+type BlameLine struct {
+	Hash   string
+	Author string
+	Date   time.Time
+}
+
+func (bl *BlameLine) ShortHash() string {
+	return bl.Hash[:min(len(bl.Hash), 7)]
+}
+
+// Blame returns the last commit to modify each line of text, which is the
+// current contents of the file at path. Lines that have been added or changed
+// since HEAD, including every line of untracked files, are nil
+func (r *Repo) Blame(path, text string) ([]*BlameLine, error) {
+	lines := make([]*BlameLine, len(strings.Split(text, "\n")))
+
+	path, err := r.relPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := git.Blame(commit, path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return lines, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var headLines []string
+	for _, line := range result.Lines {
+		headLines = append(headLines, line.Text)
+	}
+
+	// The file may have changed since HEAD, so line up the committed lines
+	// with the current ones before attributing them
+	// Every line has to end in a newline to be counted, but the empty line
+	// after a trailing newline isn't one git knows about
+	current := text
+	if !strings.HasSuffix(current, "\n") {
+		current += "\n"
+	}
+
+	var headIndex, currentIndex int
+	for _, d := range diff.Do(strings.Join(headLines, "\n")+"\n", current) {
+		numLines := strings.Count(d.Text, "\n")
+
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			for i := 0; i < numLines && headIndex < len(result.Lines) && currentIndex < len(lines); i++ {
+				blamed := result.Lines[headIndex]
+				lines[currentIndex] = &BlameLine{
+					Hash:   blamed.Hash.String(),
+					Author: blamed.AuthorName,
+					Date:   blamed.Date,
+				}
+				headIndex++
+				currentIndex++
+			}
+		case diffmatchpatch.DiffDelete:
+			headIndex += numLines
+		case diffmatchpatch.DiffInsert:
+			currentIndex += numLines
+		}
+	}
+
+	// That empty line is part of the one its newline ends
+	if n := len(lines); n > 1 && strings.HasSuffix(text, "\n") {
+		lines[n-1] = lines[n-2]
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,177 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeDeleted  ChangeType = "deleted"
+	ChangeModified ChangeType = "modified"
+	ChangeRenamed  ChangeType = "renamed"
+)
+
+// FileChange is a file that differs between two revisions. Before is empty
+// for added files, and After is empty for deleted ones
+type FileChange struct {
+	// Path relative to the repo root. For deleted files, this is the old path
+	Path string
+	// OldPath is where a renamed file used to be
+	OldPath string
+	Type    ChangeType
+	Before  string
+	After   string
+	Binary  bool
+}
+
+// ResolveRevision returns the hash of the commit that rev points to. rev can be
+// a branch, tag, hash, or an expression like HEAD~1
+func (r *Repo) ResolveRevision(rev string) (string, error) {
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", fmt.Errorf("resolving revision %s: %w", rev, err)
+	}
+
+	return hash.String(), nil
+}
+
+func (r *Repo) resolveTree(rev string) (*object.Tree, error) {
+	hash, err := r.ResolveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := r.repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	return commit.Tree()
+}
+
+// relPath turns a path that's either absolute or relative to the repo root into
+// a slash-separated path relative to the repo root, like git expects
+func (r *Repo) relPath(path string) (string, error) {
+	path = filepath.Clean(path)
+	if filepath.IsAbs(path) {
+		var err error
+		path, err = filepath.Rel(r.repoRoot, path)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	path = filepath.ToSlash(path)
+	if path == "." {
+		return "", nil
+	}
+
+	return path, nil
+}
+
+// DiffFiles returns every file under path that changed between fromRev and
+// toRev, with its contents at both revisions. Files that were moved, with or
+// without changes, are detected as renames, like git diff does
+func (r *Repo) DiffFiles(fromRev, toRev, path string) ([]*FileChange, error) {
+	fromTree, err := r.resolveTree(fromRev)
+	if err != nil {
+		return nil, err
+	}
+
+	toTree, err := r.resolveTree(toRev)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err = r.relPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), fromTree, toTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*FileChange
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+
+		fileChange := &FileChange{}
+		switch action {
+		case merkletrie.Insert:
+			fileChange.Type = ChangeAdded
+			fileChange.Path = change.To.Name
+		case merkletrie.Delete:
+			fileChange.Type = ChangeDeleted
+			fileChange.Path = change.From.Name
+		case merkletrie.Modify:
+			fileChange.Type = ChangeModified
+			fileChange.Path = change.To.Name
+			if change.From.Name != change.To.Name {
+				fileChange.Type = ChangeRenamed
+				fileChange.OldPath = change.From.Name
+			}
+		}
+
+		// Renames in or out of path count as changes to it
+		if !isUnder(fileChange.Path, path) && (fileChange.OldPath == "" || !isUnder(fileChange.OldPath, path)) {
+			continue
+		}
+
+		from, to, err := change.Files()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range []*object.File{from, to} {
+			if f == nil {
+				continue
+			}
+
+			binary, err := f.IsBinary()
+			if err != nil {
+				return nil, err
+			}
+			fileChange.Binary = fileChange.Binary || binary
+		}
+
+		if !fileChange.Binary {
+			if from != nil {
+				fileChange.Before, err = from.Contents()
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if to != nil {
+				fileChange.After, err = to.Contents()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		files = append(files, fileChange)
+	}
+
+	return files, nil
+}
+
+// isUnder reports whether the repo path file is dir, or inside it. Everything
+// is under the root, which is an empty dir
+func isUnder(file, dir string) bool {
+	return dir == "" || file == dir || strings.HasPrefix(file, dir+"/")
+}
@@ -72,11 +72,36 @@ func NewRepo(path string) (*Repo, error) {
 	}, nil
 }
 
+// NewBareRepo opens the bare repo at path. Bare repos have no worktree, so
+// only the methods that read from the object store can be used
+func NewBareRepo(path string) (*Repo, error) {
+	root, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := git.PlainOpen(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repo{
+		repo:     repo,
+		repoRoot: root,
+	}, nil
+}
+
 type Repo struct {
 	repo     *git.Repository
 	repoRoot string
 }
 
+// Root returns the absolute path to the root of the repo's worktree, or the
+// repo itself if it's bare
+func (r *Repo) Root() string {
+	return r.repoRoot
+}
+
 func (r *Repo) Status() (git.Status, error) {
 	worktree, err := r.repo.Worktree()
 	if err != nil {
@@ -122,18 +147,11 @@ func (r *Repo) LsFilesFunc(path string, fn func(f *File) error, options *LsFiles
 		return err
 	}
 
-	path = filepath.Clean(path)
-	if filepath.IsAbs(path) {
-		path, err = filepath.Rel(r.repoRoot, path)
-		if err != nil {
-			return err
-		}
+	path, err = r.relPath(path)
+	if err != nil {
+		return err
 	}
 
-	// Git doesn't like these
-	path = strings.TrimPrefix(path, "./")
-	path = strings.TrimPrefix(path, ".")
-
 	if path != "" {
 		tree, err = tree.Tree(path)
 		if err != nil {
@@ -157,25 +175,28 @@ func (r *Repo) LsFilesFunc(path string, fn func(f *File) error, options *LsFiles
 			return err
 		}
 
-		for path, info := range status {
-			if info.Staging == git.Untracked {
-				f, err := os.Open(path)
-				if err != nil {
-					return err
-				}
-
-				info, err := f.Stat()
-				if err != nil {
-					return err
-				}
-
-				err = fn(&File{
-					Name: f.Name(),
-					Mode: filemode.FileMode(info.Mode()),
-				})
-				if err != nil {
-					return err
-				}
+		for untrackedPath, info := range status {
+			if info.Staging != git.Untracked {
+				continue
+			}
+
+			// Status covers the whole worktree, so skip anything outside path
+			if path != "" && !strings.HasPrefix(untrackedPath, path+"/") {
+				continue
+			}
+
+			// Status paths are relative to the repo root, not the working directory
+			fileInfo, err := os.Stat(filepath.Join(r.repoRoot, untrackedPath))
+			if err != nil {
+				return err
+			}
+
+			err = fn(&File{
+				Name: untrackedPath,
+				Mode: filemode.FileMode(fileInfo.Mode()),
+			})
+			if err != nil {
+				return err
 			}
 		}
 	}
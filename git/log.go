@@ -0,0 +1,120 @@
+package git
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+type Commit struct {
+	Hash    string
+	Author  string
+	Date    time.Time
+	Subject string
+}
+
+func (c *Commit) ShortHash() string {
+	return c.Hash[:min(len(c.Hash), 7)]
+}
+
+// FileHistory returns the last limit commits reachable from HEAD that touched
+// the file at path, newest first
+func (r *Repo) FileHistory(path string, limit int) ([]*Commit, error) {
+	path, err := r.relPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := r.repo.Log(&git.LogOptions{
+		From:     head.Hash(),
+		Order:    git.LogOrderCommitterTime,
+		FileName: &path,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var commits []*Commit
+	err = iter.ForEach(func(c *object.Commit) error {
+		if len(commits) >= limit {
+			return storer.ErrStop
+		}
+
+		subject, _, _ := strings.Cut(c.Message, "\n")
+		commits = append(commits, &Commit{
+			Hash:    c.Hash.String(),
+			Author:  c.Author.Name,
+			Date:    c.Author.When,
+			Subject: subject,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// FileDiff returns the unified diff of the file at path introduced by the
+// commit hash, compared to its first parent
+func (r *Repo) FileDiff(hash, path string) (string, error) {
+	path, err := r.relPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := r.repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return "", err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return "", err
+		}
+
+		parentTree, err = parent.Tree()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return "", err
+	}
+
+	for _, change := range changes {
+		if change.From.Name != path && change.To.Name != path {
+			continue
+		}
+
+		patch, err := change.Patch()
+		if err != nil {
+			return "", err
+		}
+
+		return patch.String(), nil
+	}
+
+	return "", nil
+}
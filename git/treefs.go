@@ -0,0 +1,238 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// TreeFS returns the directory at path in the tree at rev as a read only
+// fs.FS, so it can be walked and read like a checkout without needing one.
+// Submodules are left out, since their contents aren't in the tree
+func (r *Repo) TreeFS(rev, path string) (fs.FS, error) {
+	tree, err := r.resolveTree(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err = r.relPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if path != "" {
+		tree, err = tree.Tree(path)
+		if err != nil {
+			return nil, fmt.Errorf("finding %s at %s: %w", path, rev, err)
+		}
+	}
+
+	return &treeFS{tree: tree}, nil
+}
+
+type treeFS struct {
+	tree *object.Tree
+}
+
+// treeFileInfo is a file or directory in a tree. Trees don't record when
+// anything was modified, so every ModTime is zero
+type treeFileInfo struct {
+	name string
+	mode fs.FileMode
+	size int64
+	file *object.File
+}
+
+func (tfi *treeFileInfo) Name() string       { return tfi.name }
+func (tfi *treeFileInfo) Size() int64        { return tfi.size }
+func (tfi *treeFileInfo) Mode() fs.FileMode  { return tfi.mode }
+func (tfi *treeFileInfo) ModTime() time.Time { return time.Time{} }
+func (tfi *treeFileInfo) IsDir() bool        { return tfi.mode.IsDir() }
+func (tfi *treeFileInfo) Sys() any           { return nil }
+
+// stat returns what's at name, which must already be a valid path
+func (t *treeFS) stat(name string) (*treeFileInfo, error) {
+	if name == "." {
+		return &treeFileInfo{name: ".", mode: fs.ModeDir | 0755}, nil
+	}
+
+	entry, err := t.tree.FindEntry(name)
+	if err != nil || entry.Mode == filemode.Submodule {
+		return nil, fs.ErrNotExist
+	}
+
+	return t.entryInfo(entry)
+}
+
+func (t *treeFS) entryInfo(entry *object.TreeEntry) (*treeFileInfo, error) {
+	mode, err := entry.Mode.ToOSFileMode()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &treeFileInfo{
+		name: entry.Name,
+		mode: mode,
+	}
+
+	if entry.Mode != filemode.Dir {
+		info.file, err = t.tree.TreeEntryFile(entry)
+		if err != nil {
+			return nil, err
+		}
+		info.size = info.file.Size
+	}
+
+	return info, nil
+}
+
+func (t *treeFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := t.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	return info, nil
+}
+
+func (t *treeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	tree := t.tree
+	if name != "." {
+		var err error
+		tree, err = t.tree.Tree(name)
+		if errors.Is(err, object.ErrDirectoryNotFound) {
+			if _, statErr := t.stat(name); statErr == nil {
+				err = errors.New("not a directory")
+			} else {
+				err = fs.ErrNotExist
+			}
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+	}
+
+	subtree := &treeFS{tree: tree}
+	var entries []fs.DirEntry
+	for i := range tree.Entries {
+		if tree.Entries[i].Mode == filemode.Submodule {
+			continue
+		}
+
+		info, err := subtree.entryInfo(&tree.Entries[i])
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+
+	// ReadDir has to sort by name, but git sorts directories as if their
+	// names ended in a slash
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	return entries, nil
+}
+
+func (t *treeFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := t.stat(name)
+	if err == nil && info.IsDir() {
+		err = errors.New("is a directory")
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	contents, err := info.file.Contents()
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return []byte(contents), nil
+}
+
+func (t *treeFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := t.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if info.IsDir() {
+		entries, err := t.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &treeDir{info: info, entries: entries}, nil
+	}
+
+	reader, err := info.file.Reader()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &treeFile{info: info, reader: reader}, nil
+}
+
+type treeFile struct {
+	info   *treeFileInfo
+	reader io.ReadCloser
+}
+
+func (tf *treeFile) Stat() (fs.FileInfo, error) { return tf.info, nil }
+func (tf *treeFile) Read(b []byte) (int, error) { return tf.reader.Read(b) }
+func (tf *treeFile) Close() error               { return tf.reader.Close() }
+
+type treeDir struct {
+	info    *treeFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (td *treeDir) Stat() (fs.FileInfo, error) { return td.info, nil }
+func (td *treeDir) Close() error               { return nil }
+
+func (td *treeDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: td.info.name, Err: errors.New("is a directory")}
+}
+
+func (td *treeDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := td.entries[td.offset:]
+	if n <= 0 {
+		td.offset = len(td.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(remaining))
+	td.offset += n
+
+	return remaining[:n], nil
+}
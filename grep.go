@@ -0,0 +1,186 @@
+package llmcat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/everestmz/llmcat/treesym"
+)
+
+// GrepOptions is what to search files for, and how much to show around it
+type GrepOptions struct {
+	// Pattern is a regular expression matched against each line
+	Pattern    string `json:"pattern"`
+	IgnoreCase bool   `json:"ignore_case"`
+	// Context is how many lines to show before and after each match, without
+	// going outside of the definition the match is in
+	Context int `json:"context"`
+}
+
+// RenderGrep searches the file at path, or the files in the directory at path
+// that pass the directory filters, and renders the lines that match. Each
+// match is shown under the signatures of the definitions it's in, and matches
+// in the same definition are shown together, with the rest of the definition
+// collapsed. It returns an empty string if nothing matches
+func RenderGrep(path string, grep *GrepOptions, options *RenderDirectoryOptions) (string, error) {
+	pattern := grep.Pattern
+	if grep.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	err = options.SetDefaults()
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	var files []string
+	var warnings []Warning
+	grepFn := func(fullPath, relPath string) error {
+		text, err := os.ReadFile(fullPath)
+		if err != nil {
+			return err
+		}
+
+		rendered, fileWarnings, err := grepFile(fullPath, relPath, string(text), re, grep, options.FileOptions)
+		if err != nil {
+			return fmt.Errorf("error searching file %s: %w", relPath, err)
+		}
+
+		if rendered != "" {
+			files = append(files, rendered)
+			warnings = append(warnings, fileWarnings...)
+		}
+
+		return nil
+	}
+
+	if info.IsDir() {
+		dirName, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+
+		// Only the directory filters apply, not any context spec
+		everything := *options
+		everything.ContextSpec = nil
+		_, err = walkDirectory(dirName, &everything, grepFn)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		err = grepFn(path, path)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	return joinRenderedFiles(files, warnings), nil
+}
+
+// grepFile renders the lines of text that match re, along with the lines
+// around them and the signatures of the definitions they're in. It returns an
+// empty string if nothing matches
+func grepFile(path, relPath, text string, re *regexp.Regexp, grep *GrepOptions, options *RenderFileOptions) (string, []Warning, error) {
+	if strings.ContainsRune(text, 0) {
+		// Binary files aren't worth showing
+		return "", nil, nil
+	}
+
+	lines := strings.Split(text, "\n")
+
+	var matches []int
+	for row, line := range lines {
+		if re.MatchString(line) {
+			matches = append(matches, row)
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", nil, nil
+	}
+
+	psf, _ := parseFile(relPath, text, options)
+
+	// Matches are grouped under the innermost definition they're in, so that
+	// each group is shown under its own breadcrumbs. Matches outside of any
+	// definition are shown on their own
+	type matchGroup struct {
+		def  *treesym.Node
+		rows []int
+	}
+
+	var groups []*matchGroup
+	for _, row := range matches {
+		first, last := max(row-grep.Context, 0), min(row+grep.Context, len(lines)-1)
+
+		var def *treesym.Node
+		if psf != nil {
+			def = innermostDefinition(psf, row)
+		}
+
+		var group *matchGroup
+		if def != nil {
+			// Context stays inside the definition
+			first = max(first, int(def.StartPoint.Row))
+			last = min(last, int(def.EndPoint.Row))
+
+			if i := slices.IndexFunc(groups, func(g *matchGroup) bool { return g.def == def }); i >= 0 {
+				group = groups[i]
+			}
+		}
+
+		if group == nil {
+			group = &matchGroup{def: def}
+			groups = append(groups, group)
+		}
+
+		for r := first; r <= last; r++ {
+			group.rows = append(group.rows, r)
+		}
+	}
+
+	var rows []int
+	shown := map[int]bool{}
+	for _, group := range groups {
+		groupRows := group.rows
+		if group.def != nil {
+			groupRows = append(breadcrumbs(psf, group.def, true, slices.Min(group.rows)), groupRows...)
+		}
+		slices.Sort(groupRows)
+
+		for _, row := range groupRows {
+			if !shown[row] {
+				shown[row] = true
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	plan := options.Plan()
+	plan.Mode = RenderFull
+	plan.Page = 0
+	plan.StartLine = 1
+	plan.PageSize = 0
+	plan.Rows = rows
+	plan.Marked = matches
+
+	return renderFile(relPath, path, text, plan)
+}
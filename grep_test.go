@@ -0,0 +1,116 @@
+package llmcat
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderGrep(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "server.go"), `package server
+
+// TODO: split this file
+type Server struct {
+	name string
+}
+
+func (s *Server) Handle() {
+	a := 1
+	// TODO: check a
+	b := 2
+	c := 3
+	// todo: check c
+	println(a, b, c)
+}
+`)
+	writeTestFile(t, filepath.Join(dir, "notes.txt"), "nothing to see here\n")
+
+	output, err := RenderGrep(dir, &GrepOptions{Pattern: "todo", IgnoreCase: true, Context: 1}, &RenderDirectoryOptions{
+		FileOptions: &RenderFileOptions{
+			OutputMarkdown:  true,
+			ShowLineNumbers: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"```server.go",
+		"   | ... (1 line above) ...",
+		"2  | ",
+		"3  > // TODO: split this file",
+		"4  | type Server struct {",
+		"   | ... (3 lines) ...",
+		"8  | func (s *Server) Handle() {",
+		"9  | \ta := 1",
+		"10 > \t// TODO: check a",
+		"11 | \tb := 2",
+		"12 | \tc := 3",
+		"13 > \t// todo: check c",
+		"14 | \tprintln(a, b, c)",
+		"   | ... (2 lines below) ...",
+		"```",
+	}, "\n")
+	if output != want {
+		t.Errorf("expected:\n%s\n\ngot:\n%s", want, output)
+	}
+
+	// A method's breadcrumbs can be above a match in an earlier function, so
+	// they're shown apart from it rather than as its context
+	writeTestFile(t, filepath.Join(dir, "work.go"), `package server
+
+type Worker struct {
+	n int
+}
+
+func Start() {
+	work()
+}
+
+func (w *Worker) Run() {
+	work()
+}
+`)
+
+	output, err = RenderGrep(filepath.Join(dir, "work.go"), &GrepOptions{Pattern: "work\\("}, &RenderDirectoryOptions{
+		FileOptions: &RenderFileOptions{ShowLineNumbers: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want = strings.Join([]string{
+		"   | ... (6 lines above) ...",
+		"7  | func Start() {",
+		"8  > \twork()",
+		"   | ... (back up to line 3) ...",
+		"3  | type Worker struct {",
+		"   | ... (down to line 11) ...",
+		"11 | func (w *Worker) Run() {",
+		"12 > \twork()",
+		"   | ... (2 lines below) ...",
+	}, "\n")
+	if !strings.Contains(output, want) {
+		t.Errorf("expected:\n%s\n\ngot:\n%s", want, output)
+	}
+
+	output, err = RenderGrep(filepath.Join(dir, "server.go"), &GrepOptions{Pattern: "todo"}, &RenderDirectoryOptions{FileOptions: &RenderFileOptions{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "> \t// todo: check c") || strings.Contains(output, "TODO") {
+		t.Errorf("expected only the lowercase match in server.go, got:\n%s", output)
+	}
+
+	output, err = RenderGrep(dir, &GrepOptions{Pattern: "nowhere"}, &RenderDirectoryOptions{FileOptions: &RenderFileOptions{}})
+	if err != nil || output != "" {
+		t.Errorf("expected no output without any matches, got %q (%v)", output, err)
+	}
+
+	if _, err := RenderGrep(dir, &GrepOptions{Pattern: "("}, &RenderDirectoryOptions{FileOptions: &RenderFileOptions{}}); err == nil {
+		t.Errorf("expected an invalid pattern to be rejected")
+	}
+}
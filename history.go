@@ -0,0 +1,62 @@
+package llmcat
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/everestmz/llmcat/git"
+	"github.com/rs/zerolog/log"
+)
+
+// renderFileHistory lists the most recent commits to touch the file at path,
+// and optionally the diff of the latest one. If path isn't in a git repo or has
+// no history, it returns an empty string
+func renderFileHistory(filename, path string, options *RenderFileOptions) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	repo, err := git.NewRepo(filepath.Dir(absPath))
+	if err != nil {
+		log.Debug().Err(err).Str("path", path).Msg("Unable to get file history")
+		return "", nil
+	}
+
+	commits, err := repo.FileHistory(absPath, options.History)
+	if err != nil {
+		return "", fmt.Errorf("getting history for %s: %w", filename, err)
+	}
+
+	if len(commits) == 0 {
+		return "", nil
+	}
+
+	outputLines := []string{fmt.Sprintf("Recent commits touching %s:", filename)}
+	for _, commit := range commits {
+		outputLines = append(outputLines, fmt.Sprintf("%s %s %s", commit.ShortHash(), commit.Date.Format("2006-01-02"), commit.Subject))
+	}
+
+	if options.ShowHistoryDiff {
+		diff, err := repo.FileDiff(commits[0].Hash, absPath)
+		if err != nil {
+			return "", fmt.Errorf("getting diff for %s: %w", filename, err)
+		}
+
+		if diff != "" {
+			outputLines = append(outputLines, "", fmt.Sprintf("Changes to %s in %s:", filename, commits[0].ShortHash()))
+			diff = strings.TrimSuffix(diff, "\n")
+			if options.OutputMarkdown {
+				diff = fmt.Sprintf("```diff\n%s\n```", diff)
+			}
+			outputLines = append(outputLines, diff)
+		}
+	}
+
+	return strings.Join(outputLines, "\n"), nil
+}
@@ -0,0 +1,88 @@
+package llmcat
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	dir := t.TempDir()
+	runTestGit(t, dir, "init", "-q")
+
+	commit := func(date, message string, files map[string]string) {
+		t.Helper()
+
+		for name, text := range files {
+			writeTestFile(t, filepath.Join(dir, name), text)
+		}
+
+		// Commits made in the same second would have no order otherwise
+		t.Setenv("GIT_COMMITTER_DATE", date)
+		runTestGit(t, dir, "add", "-A")
+		runTestGit(t, dir, "commit", "-q", "-m", message, "--date", date)
+	}
+
+	commit("2024-01-01T00:00:00Z", "Add main", map[string]string{"main.go": "package main\n\nfunc main() {\n\tprintln(1)\n}\n"})
+	commit("2024-01-02T00:00:00Z", "Print 2\n\nWith a body", map[string]string{"main.go": "package main\n\nfunc main() {\n\tprintln(2)\n}\n"})
+	commit("2024-01-03T00:00:00Z", "Add other", map[string]string{"other.go": "package main\n"})
+	commit("2024-01-04T00:00:00Z", "Print 3", map[string]string{"main.go": "package main\n\nfunc main() {\n\tprintln(3)\n}\n"})
+
+	output, err := RenderDirectory(dir, &RenderDirectoryOptions{
+		FileOptions: &RenderFileOptions{
+			OutputMarkdown:  true,
+			History:         2,
+			ShowHistoryDiff: true,
+		},
+		IncludeGlobs: []string{"**/main.go"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head := runTestGit(t, dir, "rev-parse", "--short=7", "HEAD")
+	previous := runTestGit(t, dir, "rev-parse", "--short=7", "HEAD~2")
+
+	want := strings.Join([]string{
+		"Recent commits touching main.go:",
+		head + " 2024-01-04 Print 3",
+		previous + " 2024-01-02 Print 2",
+		"",
+		"Changes to main.go in " + head + ":",
+		"```diff",
+	}, "\n")
+	if !strings.Contains(output, want) {
+		t.Fatalf("expected history:\n%s\n\ngot:\n%s", want, output)
+	}
+
+	for _, s := range []string{"-\tprintln(2)\n+\tprintln(3)", "--- a/main.go", "+++ b/main.go"} {
+		if !strings.Contains(output, s) {
+			t.Errorf("expected the diff to contain %q, got:\n%s", s, output)
+		}
+	}
+
+	for _, s := range []string{"Add main", "Add other", "With a body"} {
+		if strings.Contains(output, s) {
+			t.Errorf("expected %q not to be in the history, got:\n%s", s, output)
+		}
+	}
+
+	// A file that's never been committed has no history
+	writeTestFile(t, filepath.Join(dir, "new.go"), "package main\n")
+	output, err = RenderDirectory(dir, &RenderDirectoryOptions{
+		FileOptions:  &RenderFileOptions{OutputMarkdown: true, History: 2},
+		IncludeGlobs: []string{"**/new.go"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "new.go") || strings.Contains(output, "Recent commits") {
+		t.Errorf("expected no history for an untracked file, got:\n%s", output)
+	}
+}
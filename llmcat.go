@@ -2,12 +2,17 @@ package llmcat
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
 
 	"github.com/everestmz/llmcat/ctxspec"
 	"github.com/everestmz/llmcat/git"
@@ -18,22 +23,147 @@ import (
 )
 
 type RenderFileOptions struct {
-	Outline         bool     `json:"outline"`
-	OutputMarkdown  bool     `json:"output_markdown"`
-	ShowLineNumbers bool     `json:"hide_line_numbers"`
-	GutterSeparator string   `json:"gutter_separator"`
-	PageSize        int      `json:"page_size"`
-	StartLine       int      `json:"start_line"`
-	ShowPageInfo    bool     `json:"show_page_info"`
-	ExpandSymbols   []string `json:"expand_symbols"`
+	Outline         bool   `json:"outline"`
+	OutputMarkdown  bool   `json:"output_markdown"`
+	ShowLineNumbers bool   `json:"hide_line_numbers"`
+	GutterSeparator string `json:"gutter_separator"`
+	PageSize        int    `json:"page_size"`
+	StartLine       int    `json:"start_line"`
+	// Page picks a page of about PageSize lines to show, starting from 1,
+	// instead of StartLine. Pages only break between top-level definitions, so
+	// none are split across two pages
+	Page          int      `json:"page"`
+	ShowPageInfo  bool     `json:"show_page_info"`
+	ExpandSymbols []string `json:"expand_symbols"`
+	// OutlineDepth is how many levels of keys in data files like YAML, JSON
+	// and TOML are kept when outlining. Anything nested deeper is collapsed
+	OutlineDepth int `json:"outline_depth"`
+	// Languages maps extensions (like ".tpl") and file names (like
+	// "Jenkinsfile") to the language they should be parsed as
+	Languages map[string]string `json:"languages"`
+	// ShowBlame adds the short hash, author and age of the last commit to
+	// touch each line to the gutter
+	ShowBlame bool `json:"show_blame"`
+	// RecentlyChanged expands any symbol with lines changed more recently
+	// than this when outlining. Zero disables it
+	RecentlyChanged time.Duration `json:"recently_changed"`
+	// History appends the last N commits to touch the file after it
+	History int `json:"history"`
+	// ShowHistoryDiff also appends the diff of the most recent of those commits
+	ShowHistoryDiff bool `json:"show_history_diff"`
+	// OmittedTemplate is a text/template for the placeholder that replaces
+	// omitted symbols when outlining. It's executed with an OmittedPlaceholder
+	OmittedTemplate string `json:"omitted_template"`
+	// OnWarning is called with any problem that didn't stop a file from being
+	// rendered, like a syntax error making its outline unreliable
+	OnWarning func(w Warning) `json:"-"`
+	// Tree lists files with their line counts, without any of their contents
+	Tree bool `json:"tree"`
+	// OnRendered is called after each file is rendered with the ctxspec line
+	// that renders it the same way again, given the same options
+	OnRendered func(spec *ctxspec.FileContextSpec) `json:"-"`
 }
 
-// TODO: split this up so we produce another type which contains
-// ExpandSymbols - they're not a generic input, they're specific to a file
-func (ro *RenderFileOptions) Copy() *RenderFileOptions {
-	new := *ro
+// RenderMode is how much of a file is shown
+type RenderMode string
 
-	return &new
+const (
+	// RenderFull shows every line of the file
+	RenderFull RenderMode = "full"
+	// RenderOutline omits the bodies of definitions that aren't expanded
+	RenderOutline RenderMode = "outline"
+	// RenderTree only lists the file, without its contents
+	RenderTree RenderMode = "tree"
+)
+
+// RenderPlan is how a single file is rendered. It's built for each file from
+// the RenderFileOptions shared by every file, along with anything the context
+// spec says about that file, and isn't changed once rendering starts
+type RenderPlan struct {
+	Mode RenderMode
+	// Expand selects the definitions to show in full when outlining, by bare
+	// or qualified name
+	Expand []string
+	// ReportUnknownSymbols warns about anything in Expand that isn't in the
+	// file, for when they were asked for in this file specifically
+	ReportUnknownSymbols bool
+	// StartLine is the first line shown, starting from 1, and PageSize is the
+	// most lines shown from there. If Page is set, it's used instead of
+	// StartLine, and pages are snapped to definitions
+	StartLine int
+	PageSize  int
+	Page      int
+	// Breadcrumbs are rows from above the page, starting from 0 and in order,
+	// that are shown before it. They're for things like the signatures of the
+	// definitions the page is inside
+	Breadcrumbs []int
+	// Marked are rows, starting from 0, that are marked in the gutter, like
+	// the lines a stack trace points to
+	Marked []int
+	// Rows, if set, are the only rows on the page that are shown, starting
+	// from 0, with markers for the lines skipped between them. A row above the
+	// one before it starts a new section, for lines that don't belong with
+	// the ones above them. The file isn't outlined
+	Rows []int
+	// Format has everything else, like the gutter and history, and is shared
+	// between files. Its Outline, Tree, ExpandSymbols, StartLine, PageSize and
+	// Page are replaced by the plan's
+	Format *RenderFileOptions
+}
+
+// Plan returns the plan for rendering any file with these options, before
+// anything specific to the file is applied
+func (ro *RenderFileOptions) Plan() *RenderPlan {
+	mode := RenderFull
+	if ro.Tree {
+		mode = RenderTree
+	} else if ro.Outline {
+		mode = RenderOutline
+	}
+
+	return &RenderPlan{
+		Mode:      mode,
+		Expand:    slices.Clone(ro.ExpandSymbols),
+		StartLine: ro.StartLine,
+		PageSize:  ro.PageSize,
+		Page:      ro.Page,
+		Format:    ro,
+	}
+}
+
+// DefaultOmittedTemplate names the omitted symbol and gives the ctxspec line
+// that expands it, so that it can be copied verbatim
+const DefaultOmittedTemplate = `... ({{.Lines}} {{if eq .Lines 1}}line{{else}}lines{{end}} omitted: {{.Kind}} {{.Name}}; expand with "{{.Expand}}") ...`
+
+// OmittedPlaceholder describes an omitted symbol to OmittedTemplate
+type OmittedPlaceholder struct {
+	// Lines is how many lines were omitted from this page
+	Lines int
+	Name  string
+	Kind  string
+	// Path is the file the symbol is in
+	Path string
+	// Expand is the ctxspec line that expands the symbol
+	Expand string
+}
+
+// Warning is a problem with a file that didn't stop it from being rendered. As
+// well as being passed to OnWarning, warnings are added after the file, and
+// summarized at the end of directory renderings
+type Warning struct {
+	Path    string
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.Path, w.Message)
+}
+
+func (ro *RenderFileOptions) warn(w Warning) {
+	log.Debug().Str("path", w.Path).Msg(w.Message)
+	if ro.OnWarning != nil {
+		ro.OnWarning(w)
+	}
 }
 
 func (ro *RenderFileOptions) SetDefaults() {
@@ -48,25 +178,155 @@ func (ro *RenderFileOptions) SetDefaults() {
 	if ro.StartLine < 1 {
 		ro.StartLine = 1
 	}
+
+	if ro.OutlineDepth < 1 {
+		ro.OutlineDepth = 2
+	}
+
+	if ro.OmittedTemplate == "" {
+		ro.OmittedTemplate = DefaultOmittedTemplate
+	}
 }
 
 func RenderFile(filename, text string, options *RenderFileOptions) (string, error) {
-	log.Debug().Str("path", filename).Strs("symbols", options.ExpandSymbols).Msg("Expanding file with symbols")
-	outputLines := []string{}
-
 	options.SetDefaults()
 
+	// The file was named, so the symbols to expand should be in it
+	plan := options.Plan()
+	plan.ReportUnknownSymbols = true
+
+	return RenderFilePlan(filename, text, plan)
+}
+
+// RenderFilePlan renders text under the name filename following plan, whose
+// Format must already have had SetDefaults called
+func RenderFilePlan(filename, text string, plan *RenderPlan) (string, error) {
+	rendered, _, err := renderFile(filename, filename, text, plan)
+	return rendered, err
+}
+
+// renderFile renders text under the name filename. path is where the file lives
+// on disk, which is used to look up its git history, and may be empty. It also
+// returns any warnings, which have already been added to the rendering
+func renderFile(filename, path, text string, plan *RenderPlan) (string, []Warning, error) {
+	log.Debug().Str("path", filename).Str("mode", string(plan.Mode)).Strs("symbols", plan.Expand).Msg("Rendering file")
+	options := plan.Format
+	outputLines := []string{}
+
 	lines := strings.Split(text, "\n")
 	totalLines := len(lines)
 
+	if plan.Mode == RenderTree {
+		return fmt.Sprintf("%s (%s)", filename, countLines(totalLines)), nil, nil
+	}
+
 	gutterWidth := len(fmt.Sprint(len(lines))) + 1 // add 1 line for a space before the separator
 
+	var blame []*git.BlameLine
+	if options.ShowBlame || options.RecentlyChanged > 0 {
+		blame = blameFile(path, text)
+	}
+
+	var blameColumn []string
+	var blameWidth int
+	if options.ShowBlame && blame != nil {
+		blameColumn = formatBlameColumn(blame, time.Now())
+		for _, annotation := range blameColumn {
+			blameWidth = max(blameWidth, utf8.RuneCountInString(annotation)+1)
+		}
+	}
+
+	showGutter := options.ShowLineNumbers || blameColumn != nil || len(plan.Marked) > 0
+	emptyGutter := strings.Repeat(" ", blameWidth)
+	if options.ShowLineNumbers {
+		emptyGutter += strings.Repeat(" ", gutterWidth)
+	}
+
+	addMarkerGutter := func(marker string) string {
+		if showGutter {
+			marker = fmt.Sprintf("%s%s %s", emptyGutter, options.GutterSeparator, marker)
+		}
+
+		return marker
+	}
+
+	detector, err := language.NewDetector(options.Languages)
+	if err != nil {
+		return "", nil, err
+	}
+
+	omittedTemplate, err := template.New("omitted").Parse(options.OmittedTemplate)
+	if err != nil {
+		return "", nil, fmt.Errorf("parsing omitted template: %w", err)
+	}
+
+	var warnings []Warning
+	addWarning := func(message string) {
+		w := Warning{Path: filename, Message: message}
+		warnings = append(warnings, w)
+		options.warn(w)
+	}
+
+	var chunks *treesym.ProcessedSourceFile
+	lang, err := detector.Detect(filename, text)
+	if err == nil {
+		chunks, err = treesym.GetSymbols(context.TODO(), &treesym.SourceFile{
+			Path:     filename,
+			Text:     text,
+			Language: lang,
+		})
+	}
+	var outline []*treesym.OutlineChunk
+	var expanded []string
+	if err == nil {
+		var expandWarnings []string
+		outline = chunks.GetOutlineFunc(expandDefinitionFunc(chunks, blame, plan, func(message string) {
+			expandWarnings = append(expandWarnings, message)
+		}, func(def *treesym.Node) {
+			if !slices.Contains(expanded, def.QualifiedName) {
+				expanded = append(expanded, def.QualifiedName)
+			}
+		}))
+
+		if plan.Mode == RenderOutline {
+			for _, message := range append(chunks.Warnings, expandWarnings...) {
+				addWarning(message)
+			}
+		}
+	} else if plan.Mode == RenderOutline {
+		if !language.IsUnsupported(err) {
+			// One file we can't parse shouldn't stop everything else rendering
+			addWarning(fmt.Sprintf("couldn't outline: %v, so showing the whole file", err))
+		}
+
+		// There are no symbols to find the ones to expand among
+		if plan.ReportUnknownSymbols {
+			for _, symbol := range plan.Expand {
+				addWarning(fmt.Sprintf("no symbol %q to expand, since this file can't be outlined", symbol))
+			}
+		}
+	}
+
 	// Calculate page bounds
-	startIndex := options.StartLine - 1
+	startIndex := max(plan.StartLine-1, 0)
 	endIndex := totalLines
 
-	if options.PageSize > 0 {
-		endIndex = min(startIndex+options.PageSize, totalLines)
+	var page int
+	var pages []int
+	if plan.Page > 0 {
+		var defs []*treesym.Node
+		if chunks != nil {
+			defs = chunks.Definitions
+		}
+
+		pages = pageStarts(lines, defs, plan.PageSize)
+		page = min(plan.Page, len(pages))
+		startIndex = pages[page-1]
+		if page < len(pages) {
+			endIndex = pages[page]
+		}
+	} else if plan.PageSize > 0 {
+		endIndex = min(startIndex+plan.PageSize, totalLines)
 	}
 
 	// Validate bounds
@@ -77,106 +337,316 @@ func RenderFile(filename, text string, options *RenderFileOptions) (string, erro
 
 	if options.OutputMarkdown {
 		header := fmt.Sprintf("```%s", filename)
-		if options.ShowPageInfo && options.PageSize > 0 {
-			header += fmt.Sprintf(" (Lines %d-%d of %d)", startIndex+1, endIndex, totalLines)
+		if options.ShowPageInfo && page > 0 {
+			header += fmt.Sprintf(" (%s of %d, page %d of %d)", lineRange(startIndex+1, endIndex), totalLines, page, len(pages))
+		} else if options.ShowPageInfo && plan.PageSize > 0 {
+			header += fmt.Sprintf(" (%s of %d)", lineRange(startIndex+1, endIndex), totalLines)
 		}
 		outputLines = append(outputLines, header)
 	}
 
-	if startIndex > 0 {
-		marker := fmt.Sprintf("... (%d lines above) ...", startIndex)
-		if options.ShowLineNumbers {
-			marker = fmt.Sprintf("%s%s %s", strings.Repeat(" ", gutterWidth), options.GutterSeparator, marker)
+	addLineInfo := func(line string, offset, lineIndex int) string {
+		if !showGutter {
+			return line
+		}
+
+		lineNum := offset + lineIndex
+
+		var gutter string
+		if blameColumn != nil && lineNum <= len(blameColumn) {
+			annotation := blameColumn[lineNum-1]
+			gutter += annotation + strings.Repeat(" ", blameWidth-utf8.RuneCountInString(annotation))
+		} else {
+			gutter += strings.Repeat(" ", blameWidth)
 		}
-		outputLines = append(outputLines, marker)
-	}
 
-	addLineInfo := func(line string, offset, lineIndex int) string {
 		if options.ShowLineNumbers {
-			lineNum := offset + lineIndex
 			padding := strings.Repeat(" ", gutterWidth-len(fmt.Sprint(lineNum)))
-			line = fmt.Sprintf("%d%s%s %s", lineNum, padding, options.GutterSeparator, line)
+			gutter += fmt.Sprintf("%d%s", lineNum, padding)
+		}
+
+		separator := options.GutterSeparator
+		if slices.Contains(plan.Marked, lineNum-1) {
+			separator = strings.Repeat(">", utf8.RuneCountInString(separator))
 		}
 
-		return line
+		return fmt.Sprintf("%s%s %s", gutter, separator, line)
 	}
 
-	shouldExpandChunk := func(chunk *treesym.OutlineChunk) bool {
-		return slices.Contains(options.ExpandSymbols, chunk.Name)
+	// Breadcrumbs from above the page come first, with markers for the lines
+	// skipped around them
+	shownAbove := 0
+	for _, row := range plan.Breadcrumbs {
+		if row < shownAbove || row >= startIndex {
+			continue
+		}
+
+		if row > shownAbove {
+			outputLines = append(outputLines, addMarkerGutter(skippedLinesMarker(row-shownAbove, shownAbove == 0)))
+		}
+		outputLines = append(outputLines, addLineInfo(lines[row], row+1, 0))
+		shownAbove = row + 1
 	}
 
-	chunks, err := treesym.GetSymbols(context.TODO(), &treesym.SourceFile{
-		Path: filename,
-		Text: text,
-	})
-	outline := chunks.GetOutline()
-	if err == language.ErrUnsupportedExtension || len(outline) == 0 {
+	if startIndex > shownAbove {
+		outputLines = append(outputLines, addMarkerGutter(skippedLinesMarker(startIndex-shownAbove, shownAbove == 0)))
+	}
+
+	if len(plan.Rows) > 0 {
+		next := startIndex
+		// The row after the lowest one shown, which isn't next after going
+		// back up
+		shownBelow := startIndex
+		for i, row := range plan.Rows {
+			if row < startIndex || row >= endIndex {
+				continue
+			}
+
+			// Some of the lines skipped going down may have been shown
+			// already, in which case counting them would be misleading
+			skipsShown := slices.ContainsFunc(plan.Rows[:i], func(shown int) bool {
+				return shown >= next && shown < row
+			})
+
+			if row < next {
+				outputLines = append(outputLines, addMarkerGutter(fmt.Sprintf("... (back up to line %d) ...", row+1)))
+			} else if row > next && skipsShown {
+				outputLines = append(outputLines, addMarkerGutter(fmt.Sprintf("... (down to line %d) ...", row+1)))
+			} else if row > next {
+				outputLines = append(outputLines, addMarkerGutter(skippedLinesMarker(row-next, next == 0)))
+			}
+			outputLines = append(outputLines, addLineInfo(lines[row], row+1, 0))
+			next = row + 1
+			shownBelow = max(shownBelow, next)
+		}
+		next = shownBelow
+
+		if next < endIndex {
+			marker := fmt.Sprintf("... (%s below) ...", countLines(endIndex-next))
+			if endIndex < totalLines {
+				marker = fmt.Sprintf("... (%s) ...", countLines(endIndex-next))
+			}
+			outputLines = append(outputLines, addMarkerGutter(marker))
+		}
+	} else if len(outline) == 0 {
 		// Just print all the lines within the range
 		for lineIndex, line := range lines[startIndex:endIndex] {
 			lineNum := lineIndex + 1
 			outputLines = append(outputLines, addLineInfo(line, startIndex, lineNum))
 		}
-	} else if err != nil {
-		return "", err
 	} else {
 		for _, chunk := range outline {
-			// Tree-sitter rows are 0-indexed, our line numbers are 1-indexed
-			startLine := chunk.StartRow + 1
-			endLine := chunk.EndRow + 1
-
-			if endLine < startIndex {
+			// Only the rows of the chunk that are on this page are shown
+			firstRow := max(chunk.StartRow, startIndex)
+			lastRow := min(chunk.EndRow, endIndex-1)
+			if firstRow > lastRow {
 				continue
 			}
 
-			if startLine > endIndex {
-				continue
-			}
-
-			// This chunk is at least partially in the range
-
-			if options.Outline && chunk.ShouldOmit && !shouldExpandChunk(chunk) {
+			if plan.Mode == RenderOutline && chunk.ShouldOmit {
 				// Specify how many lines have been omitted (it may not be the size of the chunk,
 				// if some of it is on the next or previous page!)
-				var headLinesAlreadyOmitted, tailLinesAlreadyOmitted int
-				if startLine < startIndex {
-					headLinesAlreadyOmitted = startIndex - startLine
-				}
-				if endLine > endIndex {
-					tailLinesAlreadyOmitted = endLine - endIndex
-				}
-
-				// The line numbers are inclusive - so add one
-				omittedLine := fmt.Sprintf("... (%d lines omitted) ...", (endLine-startLine+1)-headLinesAlreadyOmitted-tailLinesAlreadyOmitted)
-
-				if options.ShowLineNumbers {
-					padding := strings.Repeat(" ", gutterWidth)
-					omittedLine = fmt.Sprintf("%s%s %s", padding, options.GutterSeparator, omittedLine)
+				var omittedLine strings.Builder
+				err := omittedTemplate.Execute(&omittedLine, &OmittedPlaceholder{
+					// The rows are inclusive - so add one
+					Lines:  lastRow - firstRow + 1,
+					Name:   chunk.Name,
+					Kind:   chunk.Kind,
+					Path:   filename,
+					Expand: (&ctxspec.FileContextSpec{Filename: filename, Symbols: []string{chunk.QualifiedName}}).String(),
+				})
+				if err != nil {
+					return "", nil, fmt.Errorf("executing omitted template: %w", err)
 				}
 
-				outputLines = append(outputLines, omittedLine)
+				outputLines = append(outputLines, addMarkerGutter(omittedLine.String()))
 			} else {
 				lines := strings.Split(chunk.Content, "\n")
 
-				for lineNum, line := range lines {
-					outputLines = append(outputLines, addLineInfo(line, startLine, lineNum))
+				for lineNum, line := range lines[firstRow-chunk.StartRow : lastRow-chunk.StartRow+1] {
+					// Tree-sitter rows are 0-indexed, our line numbers are 1-indexed
+					outputLines = append(outputLines, addLineInfo(line, firstRow+1, lineNum))
 				}
 			}
 		}
 	}
 
 	if endIndex < totalLines {
-		marker := fmt.Sprintf("... (%d lines below) ...", totalLines-endIndex)
-		if options.ShowLineNumbers {
-			marker = fmt.Sprintf("%s%s %s", strings.Repeat(" ", gutterWidth), options.GutterSeparator, marker)
+		marker := fmt.Sprintf("... (%s below) ...", countLines(totalLines-endIndex))
+		if page > 0 {
+			marker = fmt.Sprintf("... (%s below, continued on page %d of %d) ...", countLines(totalLines-endIndex), page+1, len(pages))
 		}
-		outputLines = append(outputLines, marker)
+		outputLines = append(outputLines, addMarkerGutter(marker))
 	}
 
 	if options.OutputMarkdown {
 		outputLines = append(outputLines, "```")
 	}
 
-	return strings.Join(outputLines, "\n"), nil
+	for _, w := range warnings {
+		outputLines = append(outputLines, "Warning: "+w.Message)
+	}
+
+	if options.History > 0 {
+		history, err := renderFileHistory(filename, path, options)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if history != "" {
+			outputLines = append(outputLines, "", history)
+		}
+	}
+
+	if options.OnRendered != nil {
+		options.OnRendered(renderedSpec(filename, plan, outline, expanded))
+	}
+
+	return strings.Join(outputLines, "\n"), warnings, nil
+}
+
+// renderedSpec is the ctxspec line that renders a file the same way again.
+// A file that was outlined is listed with the definitions that were expanded,
+// or OutlineOnly if none were, and anything else is listed on its own
+func renderedSpec(filename string, plan *RenderPlan, outline []*treesym.OutlineChunk, expanded []string) *ctxspec.FileContextSpec {
+	spec := &ctxspec.FileContextSpec{Filename: filename}
+	if plan.Mode != RenderOutline || !slices.ContainsFunc(outline, func(chunk *treesym.OutlineChunk) bool {
+		return chunk.ShouldOmit
+	}) {
+		return spec
+	}
+
+	spec.Symbols = slices.Clone(expanded)
+	if len(spec.Symbols) == 0 {
+		spec.Symbols = []string{ctxspec.OutlineOnly}
+	}
+
+	return spec
+}
+
+// skippedLinesMarker stands in for lines that aren't shown before a page. The
+// first marker is for the lines above everything else
+func skippedLinesMarker(lines int, first bool) string {
+	if first {
+		return fmt.Sprintf("... (%s above) ...", countLines(lines))
+	}
+
+	return fmt.Sprintf("... (%s) ...", countLines(lines))
+}
+
+// countLines says how many lines there are, like "1 line" or "3 lines"
+func countLines(lines int) string {
+	if lines == 1 {
+		return "1 line"
+	}
+
+	return fmt.Sprintf("%d lines", lines)
+}
+
+// lineRange names the lines from first to last, counting from 1, like
+// "Line 3" or "Lines 3-5"
+func lineRange(first, last int) string {
+	if first == last {
+		return fmt.Sprintf("Line %d", first)
+	}
+
+	return fmt.Sprintf("Lines %d-%d", first, last)
+}
+
+// joinRenderedFiles puts rendered files together, followed by a summary of
+// any warnings about them
+func joinRenderedFiles(files []string, warnings []Warning) string {
+	output := strings.Join(files, "\n\n")
+	if len(warnings) == 0 {
+		return output
+	}
+
+	summary := []string{fmt.Sprintf("Warnings (%d):", len(warnings))}
+	for _, w := range warnings {
+		summary = append(summary, "- "+w.String())
+	}
+
+	return output + "\n\n" + strings.Join(summary, "\n")
+}
+
+// expandDefinitionFunc returns whether a definition should be shown in full
+// when outlining. Expanding a symbol also expands the definitions nested inside
+// it, and the ones it's nested in, so that it's visible. Keys in data files are
+// also expanded until they're nested deeper than OutlineDepth. warn is called
+// for any symbol to expand that matches several different definitions, or
+// none at all if plan.ReportUnknownSymbols is set. expanded is called for the
+// definitions expanded in their own right, by name or by a recent change,
+// which are enough to expand everything else again
+func expandDefinitionFunc(psf *treesym.ProcessedSourceFile, blame []*git.BlameLine, plan *RenderPlan, warn func(message string), expanded func(def *treesym.Node)) func(def *treesym.Node) bool {
+	options := plan.Format
+	for _, symbol := range plan.Expand {
+		var candidates []string
+		for _, def := range psf.Definitions {
+			if def.Matches(symbol) && !slices.Contains(candidates, def.QualifiedName) {
+				candidates = append(candidates, def.QualifiedName)
+			}
+		}
+
+		if len(candidates) == 0 && plan.ReportUnknownSymbols {
+			warn(fmt.Sprintf("no symbol %q to expand%s", symbol, didYouMean(suggestSymbols(symbol, psf))))
+		}
+
+		if len(candidates) > 1 {
+			warn(fmt.Sprintf("%q matches %d definitions (%s), so expanding all of them. Use one of those names to expand just one", symbol, len(candidates), strings.Join(candidates, ", ")))
+		}
+	}
+
+	expandsDef := func(def *treesym.Node) bool {
+		return slices.ContainsFunc(plan.Expand, def.Matches)
+	}
+
+	// Ancestors that would hide an expanded definition if they were omitted.
+	// Markdown sections don't contain their subsections' lines, so those are
+	// left alone
+	enclosing := map[*treesym.Node]bool{}
+	for _, def := range psf.Definitions {
+		if !expandsDef(def) {
+			continue
+		}
+
+		for parent := def.Parent; parent != nil; parent = parent.Parent {
+			if def.StartPoint.Row <= parent.EndPoint.Row {
+				enclosing[parent] = true
+			}
+		}
+	}
+
+	recentCutoff := time.Now().Add(-options.RecentlyChanged)
+
+	return func(def *treesym.Node) bool {
+		if expandsDef(def) {
+			expanded(def)
+			return true
+		}
+
+		if def.Depth > 0 && def.Depth < options.OutlineDepth {
+			return true
+		}
+
+		if enclosing[def] {
+			return true
+		}
+
+		for node := def.Parent; node != nil; node = node.Parent {
+			if expandsDef(node) {
+				return true
+			}
+		}
+
+		// Only the part that would be omitted counts as a change to the definition
+		startRow, endRow := int(def.SummaryEndPoint.Row)+1, int(def.EndPoint.Row)
+		if options.RecentlyChanged > 0 && blame != nil && changedSince(blame, startRow, endRow, recentCutoff) {
+			expanded(def)
+			return true
+		}
+
+		return false
+	}
 }
 
 // We should probably allow for glob-based ignores, extension-based ignores, and some other dir-based filters
@@ -187,14 +657,48 @@ type RenderDirectoryOptions struct {
 	IncludeExtensions []string            `json:"include_extensions"`
 	ExcludeExtensions []string            `json:"exclude_extensions"`
 	ContextSpec       ctxspec.ContextSpec `json:"context_spec"`
+	// SpecMode is whether only the files in ContextSpec are rendered, or
+	// every file with the spec applied on top. Defaults to SpecOnly
+	SpecMode SpecMode `json:"spec_mode"`
+	// Page splits the rendered files into pages of at most PageLines lines
+	// and PageTokens tokens, and picks one to return, starting from 1. Zero
+	// returns everything
+	Page       int `json:"page"`
+	PageLines  int `json:"page_lines"`
+	PageTokens int `json:"page_tokens"`
 
 	compiledIgnoreGlobs  []glob.Glob
 	compiledIncludeGlobs []glob.Glob
 }
 
+// SpecMode is how a context spec picks the files to render
+type SpecMode string
+
+const (
+	// SpecOnly renders just the files in the spec
+	SpecOnly SpecMode = "only"
+	// SpecOverlay renders every file, expanding what the spec selects
+	SpecOverlay SpecMode = "overlay"
+)
+
+// DefaultPageLines is how long directory pages are if no limit is given
+const DefaultPageLines = 2000
+
 func (rdo *RenderDirectoryOptions) SetDefaults() error {
 	rdo.FileOptions.SetDefaults()
 
+	switch rdo.SpecMode {
+	case "":
+		rdo.SpecMode = SpecOnly
+	case SpecOnly, SpecOverlay:
+	default:
+		return fmt.Errorf("unknown spec mode %q, expected %q or %q", rdo.SpecMode, SpecOnly, SpecOverlay)
+	}
+
+	if rdo.Page > 0 && rdo.PageLines == 0 && rdo.PageTokens == 0 {
+		rdo.PageLines = DefaultPageLines
+	}
+
 	if rdo.IncludeExtensions != nil && rdo.ExcludeExtensions != nil {
 		return fmt.Errorf("cannot specify extensions to inlcude and exclude")
 	}
@@ -232,150 +736,477 @@ func (rdo *RenderDirectoryOptions) SetDefaults() error {
 	return nil
 }
 
+// ignoresDir reports whether everything inside the directory at path is
+// ignored by the ignore globs
+func (rdo *RenderDirectoryOptions) ignoresDir(path string) bool {
+	return slices.ContainsFunc(rdo.compiledIgnoreGlobs, func(g glob.Glob) bool {
+		return g.Match(path + string(filepath.Separator))
+	})
+}
+
+// includeFile returns true if the file at path passes the directory filters
+func (rdo *RenderDirectoryOptions) includeFile(path string, mode fs.FileMode) bool {
+	for _, ignoreGlob := range rdo.compiledIgnoreGlobs {
+		if ignoreGlob.Match(path) {
+			log.Debug().Str("file", path).Str("glob", fmt.Sprint(ignoreGlob)).Msgf("Ignored file")
+			return false
+		}
+	}
+
+	if len(rdo.compiledIncludeGlobs) > 0 {
+		include := false
+		for _, includeGlob := range rdo.compiledIncludeGlobs {
+			if includeGlob.Match(path) {
+				log.Debug().Str("file", path).Str("glob", fmt.Sprint(includeGlob)).Msgf("Included file")
+				include = true
+			}
+		}
+
+		if !include {
+			return false
+		}
+	}
+
+	extension := filepath.Ext(path)
+
+	if slices.Contains(rdo.ExcludeExtensions, extension) {
+		log.Debug().Str("file", path).Msgf("Excluding because extension matches excludes")
+		return false
+	}
+
+	if len(rdo.IncludeExtensions) > 0 {
+		if !slices.Contains(rdo.IncludeExtensions, extension) {
+			log.Debug().Str("file", path).Msgf("Excluding because extension is not included")
+			return false
+		}
+	}
+
+	// Check if file has execute permission using file mode bits
+	if mode&0111 != 0 {
+		return false
+	}
+
+	return true
+}
+
 func RenderDirectory(dirName string, options *RenderDirectoryOptions) (string, error) {
-	var files []string
+	dirName, err := filepath.Abs(dirName)
+	if err != nil {
+		return "", err
+	}
+
+	return renderDirectory(localDirectory(dirName), options)
+}
+
+// renderDirectory renders the files in dir that pass the directory filters
+// and the context spec, whether they're on disk or in a git tree
+func renderDirectory(dir *directory, options *RenderDirectoryOptions) (string, error) {
+	var files []*renderedFile
+	var warnings []Warning
 
 	err := options.SetDefaults()
 	if err != nil {
 		return "", err
 	}
 
-	dirName, err = filepath.Abs(dirName)
+	// There's no worktree for absolute paths in a git tree to be in
+	specRoot := dir.name
+	if !dir.onDisk() {
+		specRoot = ""
+	}
+
+	options, warnings = options.withResolvedSpec(specRoot)
+	if len(options.ContextSpec) == 0 && len(warnings) > 0 && options.SpecMode != SpecOverlay {
+		// Everything in the spec was left out, which isn't the same as not
+		// having a spec at all
+		return joinRenderedFiles(nil, warnings), nil
+	}
+
+	walkWarnings, err := walkFiles(dir, options, func(path, relPath string) error {
+		text, err := dir.readFile(relPath)
+		if err != nil {
+			return err
+		}
+
+		rendered, fileWarnings, err := renderDirectoryFile(path, relPath, text, options)
+		if err != nil {
+			return err
+		}
+		files = append(files, &renderedFile{
+			relPath:  relPath,
+			path:     path,
+			text:     text,
+			rendered: rendered,
+			warnings: fileWarnings,
+		})
+
+		return nil
+	})
 	if err != nil {
 		return "", err
 	}
 
-	walkFilesFunc := func(path string, info os.FileInfo, err error) error {
-		for _, ignoreGlob := range options.compiledIgnoreGlobs {
-			if ignoreGlob.Match(path) {
-				log.Debug().Str("file", path).Str("glob", fmt.Sprint(ignoreGlob)).Msgf("Ignored file")
-				return nil
-			}
-		}
+	return options.joinDirectory(files, warnings, walkWarnings)
+}
 
-		if len(options.compiledIncludeGlobs) > 0 {
-			include := false
-			for _, includeGlob := range options.compiledIncludeGlobs {
-				if includeGlob.Match(path) {
-					log.Debug().Str("file", path).Str("glob", fmt.Sprint(includeGlob)).Msgf("Included file")
-					include = true
-				}
-			}
+// withResolvedSpec returns a copy of the options with context spec paths
+// relative to root, the directory being rendered. Spec paths can already be
+// relative to root, with or without a leading ./, or they can be absolute.
+// Absolute paths outside of root, or any absolute paths if root is empty,
+// can't be rendered, so they're left out with a warning
+func (rdo *RenderDirectoryOptions) withResolvedSpec(root string) (*RenderDirectoryOptions, []Warning) {
+	if len(rdo.ContextSpec) == 0 {
+		return rdo, nil
+	}
+
+	var warnings []Warning
+	var items []*ctxspec.FileContextSpec
+	for _, filename := range slices.Sorted(maps.Keys(rdo.ContextSpec)) {
+		spec := rdo.ContextSpec[filename]
 
-			if !include {
-				return nil
+		path, negated := strings.CutPrefix(spec.Filename, "!")
+		if filepath.IsAbs(path) {
+			relPath, err := filepath.Rel(root, path)
+			if root == "" || err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+				w := Warning{Path: spec.Filename, Message: "this context spec path is outside of the rendered directory, so it's ignored"}
+				rdo.FileOptions.warn(w)
+				warnings = append(warnings, w)
+				continue
 			}
+			path = relPath
 		}
 
-		if info.IsDir() {
-			return nil
+		path = filepath.ToSlash(filepath.Clean(path))
+		if negated {
+			path = "!" + path
 		}
 
-		extension := filepath.Ext(path)
+		items = append(items, &ctxspec.FileContextSpec{Filename: path, Symbols: slices.Clone(spec.Symbols)})
+	}
 
-		if slices.Contains(options.ExcludeExtensions, extension) {
-			log.Debug().Str("file", path).Msgf("Excluding because extension matches excludes")
-			return nil
-		}
+	resolved := *rdo
+	resolved.ContextSpec = ctxspec.MergeContextSpecs(items...)
 
-		if len(options.IncludeExtensions) > 0 {
-			if !slices.Contains(options.IncludeExtensions, extension) {
-				log.Debug().Str("file", path).Msgf("Excluding because extension is not included")
-				return nil
-			}
+	return &resolved, warnings
+}
+
+// planFile works out how to render the file at relPath, from the file options
+// and what the context spec says about it
+func (rdo *RenderDirectoryOptions) planFile(relPath string) *RenderPlan {
+	plan := rdo.FileOptions.Plan()
+
+	if spec, ok := rdo.ContextSpec.Match(relPath); ok {
+		if len(spec.Symbols) > 0 {
+			plan.Expand = spec.ExpandedSymbols()
+			// Patterns' symbols are only expected in some of the files they match
+			_, named := rdo.ContextSpec[filepath.ToSlash(relPath)]
+			plan.ReportUnknownSymbols = named
+		} else {
+			// Just show everything
+			plan.Mode = RenderFull
 		}
+	}
+
+	return plan
+}
+
+func renderDirectoryFile(path, relPath, text string, options *RenderDirectoryOptions) (string, []Warning, error) {
+	rendered, warnings, err := renderFile(relPath, path, text, options.planFile(relPath))
+	if err != nil {
+		return "", nil, fmt.Errorf("error rendering file %s: %w", relPath, err)
+	}
 
-		// Check if file has execute permission using file mode bits
-		if info.Mode()&0111 != 0 {
+	return rendered, warnings, nil
+}
+
+// directory is a directory to render files from, either on disk or in a git
+// tree that isn't checked out
+type directory struct {
+	// name is the directory's absolute path, or where it would be if it were
+	// checked out. The directory filters match paths under it
+	name string
+	// fsys has the files of a git tree, and is nil for directories on disk
+	fsys fs.FS
+}
+
+func localDirectory(name string) *directory {
+	return &directory{name: name}
+}
+
+func (d *directory) onDisk() bool {
+	return d.fsys == nil
+}
+
+// path returns where the file at relPath is on disk, or an empty string if
+// it's only in a git tree
+func (d *directory) path(relPath string) string {
+	if !d.onDisk() {
+		return ""
+	}
+
+	return filepath.Join(d.name, relPath)
+}
+
+func (d *directory) stat(relPath string) (fs.FileInfo, error) {
+	if d.onDisk() {
+		return os.Stat(d.path(relPath))
+	}
+
+	name := filepath.ToSlash(relPath)
+	if !fs.ValidPath(name) {
+		// Like ../elsewhere, which can't be in the tree
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return fs.Stat(d.fsys, name)
+}
+
+func (d *directory) readFile(relPath string) (string, error) {
+	var text []byte
+	var err error
+	if d.onDisk() {
+		text, err = os.ReadFile(d.path(relPath))
+	} else {
+		text, err = fs.ReadFile(d.fsys, filepath.ToSlash(relPath))
+	}
+
+	return string(text), err
+}
+
+// walkDirectory calls fn for every file in dirName that passes the directory
+// filters, with the path to read it from and its path relative to dirName. It
+// returns warnings for files in the context spec that don't exist. dirName
+// must be absolute, and options must already have had SetDefaults called
+func walkDirectory(dirName string, options *RenderDirectoryOptions, fn func(path, relPath string) error) ([]Warning, error) {
+	return walkFiles(localDirectory(dirName), options, fn)
+}
+
+// walkFiles is walkDirectory for any directory. Files in a git tree have no
+// path on disk, so fn is called with an empty path for them
+func walkFiles(dir *directory, options *RenderDirectoryOptions, fn func(path, relPath string) error) ([]Warning, error) {
+	walkFilesFunc := func(relPath string, info fs.FileInfo) error {
+		// Filter on the path the file would have if it were checked out
+		if info.IsDir() || !options.includeFile(filepath.Join(dir.name, relPath), info.Mode()) {
 			return nil
 		}
 
-		text, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
+		return fn(dir.path(relPath), relPath)
+	}
 
-		relPath := path
-		if filepath.IsAbs(path) {
-			relPath, err = filepath.Rel(dirName, path)
+	if len(options.ContextSpec) > 0 && options.SpecMode == SpecOverlay {
+		return walkSpecOverlay(dir, options, fn)
+	} else if len(options.ContextSpec) > 0 {
+		var warnings []Warning
+		for _, relPath := range options.ContextSpec.Files() {
+			if _, ok := options.ContextSpec.Match(relPath); !ok {
+				// Excluded by a negation
+				continue
+			}
+
+			info, err := dir.stat(relPath)
+			if errors.Is(err, fs.ErrNotExist) {
+				warnings = append(warnings, missingSpecFileWarning(dir, relPath, options))
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("unable to stat file (%s) in context spec: %w", relPath, err)
+			}
+
+			err = walkFilesFunc(filepath.FromSlash(relPath), info)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 
-		fileOpts := options.FileOptions
-		if spec, ok := options.ContextSpec[relPath]; ok {
-			fileOptions := fileOpts.Copy()
-			if len(spec.Symbols) > 0 {
-				fileOpts.ExpandSymbols = spec.Symbols
-			} else {
-				// Just show everything
-				fileOptions.Outline = false
+		if len(options.ContextSpec.Patterns()) > 0 {
+			patternWarnings, err := walkSpecPatterns(dir, options, fn)
+			if err != nil {
+				return nil, err
 			}
+			warnings = append(warnings, patternWarnings...)
 		}
-		rendered, err := RenderFile(relPath, string(text), fileOpts)
-		if err != nil {
-			return fmt.Errorf("error rendering file %s: %w", relPath, err)
-		}
-		files = append(files, rendered)
 
-		return nil
+		return warnings, nil
 	}
 
-	repoRoot, isGitRepo := git.FindRepoRoot(".")
-
-	if options.ContextSpec != nil {
-		for path := range options.ContextSpec {
-			info, err := os.Stat(path)
+	if !dir.onDisk() {
+		// A tree only has tracked files in it, so there's nothing to ignore
+		return nil, fs.WalkDir(dir.fsys, ".", func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
-				return "", fmt.Errorf("unable to stat file (%s) in context spec: %w", path, err)
+				return err
 			}
 
-			err = walkFilesFunc(path, info, nil)
+			info, err := d.Info()
 			if err != nil {
-				return "", err
+				return err
 			}
-		}
-	} else if isGitRepo {
-		repo, err := git.NewRepo(repoRoot)
-		if err != nil {
-			return "", err
-		}
 
-		relativeToRoot, err := filepath.Rel(repoRoot, dirName)
-		if err != nil {
-			return "", err
-		}
+			return walkFilesFunc(filepath.FromSlash(path), info)
+		})
+	}
 
-		err = repo.LsFilesFunc(relativeToRoot, func(f *git.File) error {
-			info, err := os.Stat(f.Name)
+	repoRoot, isGitRepo := git.FindRepoRoot(dir.name)
+	if !isGitRepo {
+		return nil, filepath.WalkDir(dir.name, func(path string, d fs.DirEntry, err error) error {
+			info, err := d.Info()
 			if err != nil {
 				return err
 			}
 
-			return walkFilesFunc(f.Name, info, nil)
-		}, &git.LsFilesOptions{
-			// TODO: maybe we make this an option the user can pass in?
-			IncludeUntrackedFiles: true,
-		})
-
-		if err != nil {
-			return "", err
-		}
-	} else {
-		err = filepath.WalkDir(dirName, func(path string, d fs.DirEntry, err error) error {
-			info, err := d.Info()
+			relPath, err := filepath.Rel(dir.name, path)
 			if err != nil {
 				return err
 			}
 
-			return walkFilesFunc(path, info, err)
+			return walkFilesFunc(relPath, info)
 		})
 	}
 
+	repo, err := git.NewRepo(repoRoot)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	relativeToRoot, err := filepath.Rel(repoRoot, dir.name)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, repo.LsFilesFunc(relativeToRoot, func(f *git.File) error {
+		path := filepath.Join(repoRoot, f.Name)
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			// Deleted but not committed yet, or outside a sparse checkout
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir.name, path)
+		if err != nil {
+			return err
+		}
+
+		return walkFilesFunc(relPath, info)
+	}, &git.LsFilesOptions{
+		// TODO: maybe we make this an option the user can pass in?
+		IncludeUntrackedFiles: true,
+	})
+}
+
+// walkSpecPatterns calls fn for every file in dir that's selected by a
+// pattern in the context spec, rather than by name, and warns about patterns
+// that don't match anything
+func walkSpecPatterns(dir *directory, options *RenderDirectoryOptions, fn func(path, relPath string) error) ([]Warning, error) {
+	everything := *options
+	everything.ContextSpec = nil
+
+	patterns := options.ContextSpec.Patterns()
+	matched := map[*ctxspec.FileContextSpec]bool{}
+
+	_, err := walkFiles(dir, &everything, func(path, relPath string) error {
+		markMatchedPatterns(patterns, relPath, matched)
+
+		if _, named := options.ContextSpec[filepath.ToSlash(relPath)]; named {
+			// Already walked by name
+			return nil
+		}
+
+		if _, ok := options.ContextSpec.Match(relPath); !ok {
+			return nil
+		}
+
+		return fn(path, relPath)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return strings.Join(files, "\n\n"), nil
+	return unmatchedPatternWarnings(patterns, matched, options), nil
+}
+
+// walkSpecOverlay calls fn for every file in dir that passes the directory
+// filters, unless the context spec excludes it. It warns about files in the
+// spec that don't exist, and patterns that don't match anything
+func walkSpecOverlay(dir *directory, options *RenderDirectoryOptions, fn func(path, relPath string) error) ([]Warning, error) {
+	everything := *options
+	everything.ContextSpec = nil
+
+	patterns := options.ContextSpec.Patterns()
+	matched := map[*ctxspec.FileContextSpec]bool{}
+	seen := map[string]bool{}
+
+	_, err := walkFiles(dir, &everything, func(path, relPath string) error {
+		seen[filepath.ToSlash(relPath)] = true
+		markMatchedPatterns(patterns, relPath, matched)
+
+		if options.ContextSpec.Excludes(relPath) {
+			return nil
+		}
+
+		return fn(path, relPath)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []Warning
+	for _, relPath := range options.ContextSpec.Files() {
+		if seen[relPath] || options.ContextSpec.Excludes(relPath) {
+			continue
+		}
+
+		// Files the directory filters leave out aren't rendered either, so
+		// only missing ones are worth a warning
+		_, err := dir.stat(relPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			warnings = append(warnings, missingSpecFileWarning(dir, relPath, options))
+		}
+	}
+
+	return append(warnings, unmatchedPatternWarnings(patterns, matched, options)...), nil
+}
+
+// markMatchedPatterns records which of the spec's patterns select relPath
+func markMatchedPatterns(patterns []*ctxspec.FileContextSpec, relPath string, matched map[*ctxspec.FileContextSpec]bool) {
+	for _, pattern := range patterns {
+		if !pattern.IsNegated() && pattern.MatchesPath(relPath) {
+			matched[pattern] = true
+		}
+	}
+}
+
+// unmatchedPatternWarnings warns about the spec's patterns that didn't match
+// any files
+func unmatchedPatternWarnings(patterns []*ctxspec.FileContextSpec, matched map[*ctxspec.FileContextSpec]bool, options *RenderDirectoryOptions) []Warning {
+	var warnings []Warning
+	for _, pattern := range patterns {
+		if !pattern.IsNegated() && !matched[pattern] {
+			w := Warning{Path: pattern.Filename, Message: "no files match this pattern in the context spec"}
+			options.FileOptions.warn(w)
+			warnings = append(warnings, w)
+		}
+	}
+
+	return warnings
+}
+
+// missingSpecFileWarning reports a file in the context spec that doesn't
+// exist, suggesting files in dir with similar names
+func missingSpecFileWarning(dir *directory, path string, options *RenderDirectoryOptions) Warning {
+	everything := *options
+	everything.ContextSpec = nil
+
+	var paths []string
+	_, err := walkFiles(dir, &everything, func(_, relPath string) error {
+		paths = append(paths, relPath)
+		return nil
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("Listing files to suggest instead of a missing one")
+	}
+
+	w := Warning{Path: path, Message: "no such file in the context spec" + didYouMean(suggestFiles(path, paths))}
+	options.FileOptions.warn(w)
+
+	return w
 }
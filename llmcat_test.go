@@ -0,0 +1,760 @@
+package llmcat
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/everestmz/llmcat/ctxspec"
+)
+
+func TestRenderDirectoryWarnings(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.go"), "package main\n\nfunc broken( {\n\treturn\n}\n")
+	writeTestFile(t, filepath.Join(dir, "b.go"), "package main\n\nfunc ok() {\n\treturn\n}\n")
+
+	var warnings []Warning
+	output, err := RenderDirectory(dir, &RenderDirectoryOptions{
+		FileOptions: &RenderFileOptions{
+			Outline:        true,
+			OutputMarkdown: true,
+			OnWarning: func(w Warning) {
+				warnings = append(warnings, w)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(warnings) != 1 || warnings[0].Path != "a.go" {
+		t.Fatalf("expected one warning for a.go, got %v", warnings)
+	}
+
+	for _, s := range []string{
+		"```b.go",
+		"Warning: syntax error on line 3",
+		"Warnings (1):\n- a.go: syntax error on line 3",
+	} {
+		if !strings.Contains(output, s) {
+			t.Errorf("expected output to contain %q, got:\n%s", s, output)
+		}
+	}
+}
+
+func TestRenderFileWithoutOutline(t *testing.T) {
+	// None of these can be outlined, so they're shown in full rather than
+	// failing
+	for _, filename := range []string{"notes.xyz", "Dockerfile", "Makefile"} {
+		t.Run(filename, func(t *testing.T) {
+			text := "FROM scratch\nCOPY . .\n"
+			output, err := RenderFile(filename, text, &RenderFileOptions{Outline: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if output != text {
+				t.Errorf("expected the whole file, got:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestOmittedPlaceholder(t *testing.T) {
+	text := "package main\n\nfunc Render() {\n\ta := 1\n\tb := 2\n\tprintln(a + b)\n}\n"
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{want: `... (4 lines omitted: function Render; expand with "pkg/llmcat.go Render") ...`},
+		{template: "/* {{.Lines}} {{.Path}} {{.Expand}} */", want: "/* 4 pkg/llmcat.go pkg/llmcat.go Render */"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			output, err := RenderFile("pkg/llmcat.go", text, &RenderFileOptions{
+				Outline:         true,
+				OmittedTemplate: tt.template,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(output, tt.want) {
+				t.Fatalf("expected output to contain %q, got:\n%s", tt.want, output)
+			}
+		})
+	}
+
+	output, err := RenderFile("render.py", "def render():\n    return 1\n", &RenderFileOptions{Outline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `... (1 line omitted: function render; expand with "render.py render") ...`; !strings.Contains(output, want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, output)
+	}
+
+	if _, err := RenderFile("llmcat.go", text, &RenderFileOptions{Outline: true, OmittedTemplate: "{{.Lines"}); err == nil {
+		t.Errorf("expected an invalid template to be rejected")
+	}
+}
+
+func TestExpandQualifiedSymbols(t *testing.T) {
+	text := `package buffer
+
+type Buffer struct{}
+
+func (b *Buffer) String() string {
+	return "buffer"
+}
+
+type List struct{}
+
+func (l *List) String() string {
+	return "list"
+}
+`
+
+	tests := []struct {
+		symbol   string
+		expanded []string
+		warning  string
+	}{
+		{symbol: "List.String", expanded: []string{`"list"`}},
+		{symbol: "String", expanded: []string{`"buffer"`, `"list"`}, warning: `"String" matches 2 definitions (Buffer.String, List.String)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			var warnings []string
+			output, err := RenderFile("buffer.go", text, &RenderFileOptions{
+				Outline:       true,
+				ExpandSymbols: []string{tt.symbol},
+				OnWarning: func(w Warning) {
+					warnings = append(warnings, w.Message)
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, body := range []string{`"buffer"`, `"list"`} {
+				if strings.Contains(output, body) != slices.Contains(tt.expanded, body) {
+					t.Errorf("expected %s to be expanded only if it's in %v, got:\n%s", body, tt.expanded, output)
+				}
+			}
+
+			if tt.warning == "" && len(warnings) > 0 {
+				t.Errorf("expected no warnings, got %v", warnings)
+			}
+			if tt.warning != "" && (len(warnings) != 1 || !strings.HasPrefix(warnings[0], tt.warning)) {
+				t.Errorf("expected a warning starting %q, got %v", tt.warning, warnings)
+			}
+		})
+	}
+}
+
+func TestContextSpecWarnings(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "render.go"), "package main\n\nfunc RenderFile() {\n\treturn\n}\n")
+	writeTestFile(t, filepath.Join(dir, "other.go"), "package main\n\nfunc Other() {\n\treturn\n}\n")
+	writeTestFile(t, filepath.Join(dir, "Dockerfile"), "FROM scratch\n")
+
+	spec, err := ctxspec.ParseContextSpec("render.go RendrFile RenderFile\nrender.og\nother.go\nDockerfile build")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings []string
+	output, err := RenderDirectory(dir, &RenderDirectoryOptions{
+		ContextSpec: spec,
+		FileOptions: &RenderFileOptions{
+			Outline:        true,
+			OutputMarkdown: true,
+			OnWarning: func(w Warning) {
+				warnings = append(warnings, w.String())
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`Dockerfile: no symbol "build" to expand, since this file can't be outlined`,
+		`render.go: no symbol "RendrFile" to expand. Did you mean RenderFile?`,
+		`render.og: no such file in the context spec. Did you mean render.go?`,
+	}
+	if fmt.Sprint(warnings) != fmt.Sprint(expected) {
+		t.Fatalf("got warnings %q, expected %q", warnings, expected)
+	}
+
+	if !strings.Contains(output, "Warnings (3):") {
+		t.Errorf("expected warnings to be summarized, got:\n%s", output)
+	}
+}
+
+func TestRenderPlansDontLeak(t *testing.T) {
+	dir := t.TempDir()
+	text := "package main\n\nfunc Shared() {\n\treturn\n}\n\nfunc Other() {\n\treturn\n}\n"
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		writeTestFile(t, filepath.Join(dir, name), text)
+	}
+
+	spec, err := ctxspec.ParseContextSpec("a.go Shared\nb.go\nc.go Other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileOptions := &RenderFileOptions{
+		Outline:        true,
+		OutputMarkdown: true,
+		ExpandSymbols:  []string{"Global"},
+	}
+	options := &RenderDirectoryOptions{
+		ContextSpec: spec,
+		FileOptions: fileOptions,
+	}
+
+	output, err := RenderDirectory(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file    string
+		omitted []string
+	}{
+		{file: "a.go", omitted: []string{"Other"}},
+		{file: "b.go"},
+		{file: "c.go", omitted: []string{"Shared"}},
+	}
+
+	// Each file's expansions should only come from its own spec line
+	for _, tt := range tests {
+		_, rendered, ok := strings.Cut(output, "```"+tt.file)
+		if !ok {
+			t.Fatalf("expected %s to be rendered, got:\n%s", tt.file, output)
+		}
+		rendered, _, _ = strings.Cut(rendered, "\n```")
+
+		for _, symbol := range []string{"Shared", "Other"} {
+			omitted := strings.Contains(rendered, "function "+symbol+";")
+			if omitted != slices.Contains(tt.omitted, symbol) {
+				t.Errorf("expected %s to be omitted from %s only if it's in %v, got:\n%s", symbol, tt.file, tt.omitted, rendered)
+			}
+		}
+	}
+
+	if !fileOptions.Outline || fmt.Sprint(fileOptions.ExpandSymbols) != "[Global]" {
+		t.Errorf("rendering changed the shared options: %+v", fileOptions)
+	}
+
+	plan := options.planFile("a.go")
+	plan.Expand[0] = "Changed"
+	if spec["a.go"].Symbols[0] != "Shared" {
+		t.Errorf("changing a plan changed the context spec")
+	}
+}
+
+func TestRenderDirectorySpecPatterns(t *testing.T) {
+	dir := t.TempDir()
+	text := "package api\n\nfunc Handler() {\n\treturn\n}\n\nfunc helper() {\n\treturn\n}\n"
+	for _, name := range []string{"internal/api/routes.go", "internal/api/routes_test.go", "internal/db/conn.go", "main.go"} {
+		writeTestFile(t, filepath.Join(dir, name), text)
+	}
+
+	spec, err := ctxspec.ParseContextSpec("internal/**/*.go Handler\n!**/*_test.go\ndocs/*.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings []string
+	output, err := RenderDirectory(dir, &RenderDirectoryOptions{
+		ContextSpec: spec,
+		FileOptions: &RenderFileOptions{
+			Outline:        true,
+			OutputMarkdown: true,
+			OnWarning: func(w Warning) {
+				warnings = append(warnings, w.String())
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"internal/api/routes.go", "internal/db/conn.go"} {
+		if !strings.Contains(output, "```"+file) {
+			t.Errorf("expected %s to be rendered, got:\n%s", file, output)
+		}
+	}
+
+	for _, s := range []string{"routes_test.go", "main.go", "function Handler;"} {
+		if strings.Contains(output, s) {
+			t.Errorf("expected output not to contain %q, got:\n%s", s, output)
+		}
+	}
+
+	expected := []string{"docs/*.md: no files match this pattern in the context spec"}
+	if fmt.Sprint(warnings) != fmt.Sprint(expected) {
+		t.Errorf("got warnings %q, expected %q", warnings, expected)
+	}
+}
+
+func TestContextSpecPaths(t *testing.T) {
+	for _, isGitRepo := range []bool{false, true} {
+		t.Run(fmt.Sprintf("git=%v", isGitRepo), func(t *testing.T) {
+			dir := t.TempDir()
+			text := "package main\n\nfunc Run() {\n\treturn\n}\n"
+			for _, name := range []string{"a.go", "sub/b.go", "sub/c.go", "other.go"} {
+				writeTestFile(t, filepath.Join(dir, name), text)
+			}
+			if isGitRepo {
+				runTestGit(t, dir, "init")
+				runTestGit(t, dir, "add", "-A")
+				runTestGit(t, dir, "commit", "-m", "Initial commit")
+			}
+
+			spec, err := ctxspec.ParseContextSpec(strings.Join([]string{
+				"./a.go Run",
+				"sub/../a.go",
+				filepath.Join(dir, "sub", "b.go"),
+				"./sub/*.go Run",
+				"!" + filepath.Join(dir, "sub", "c.go"),
+				"/elsewhere/d.go",
+			}, "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var warnings []string
+			output, err := RenderDirectory(dir, &RenderDirectoryOptions{
+				ContextSpec: spec,
+				FileOptions: &RenderFileOptions{
+					Outline:        true,
+					OutputMarkdown: true,
+					OnWarning: func(w Warning) {
+						warnings = append(warnings, w.String())
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, file := range []string{"a.go", "sub/b.go"} {
+				if !strings.Contains(output, "```"+file) {
+					t.Errorf("expected %s to be rendered, got:\n%s", file, output)
+				}
+			}
+
+			for _, file := range []string{"sub/c.go", "other.go"} {
+				if strings.Contains(output, "```"+file) {
+					t.Errorf("expected %s not to be rendered, got:\n%s", file, output)
+				}
+			}
+
+			expected := []string{"/elsewhere/d.go: this context spec path is outside of the rendered directory, so it's ignored"}
+			if fmt.Sprint(warnings) != fmt.Sprint(expected) {
+				t.Errorf("got warnings %q, expected %q", warnings, expected)
+			}
+		})
+	}
+}
+
+func TestSpecModeOverlay(t *testing.T) {
+	dir := t.TempDir()
+	text := "package main\n\nfunc Run() {\n\treturn\n}\n"
+	for _, name := range []string{"a.go", "b.go", "vendor/c.go"} {
+		writeTestFile(t, filepath.Join(dir, name), text)
+	}
+
+	spec, err := ctxspec.ParseContextSpec("a.go Run\n!vendor/**")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := RenderDirectory(dir, &RenderDirectoryOptions{
+		ContextSpec: spec,
+		SpecMode:    SpecOverlay,
+		FileOptions: &RenderFileOptions{
+			Outline:        true,
+			OutputMarkdown: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file     string
+		rendered bool
+		omitted  bool
+	}{
+		{file: "a.go", rendered: true},
+		{file: "b.go", rendered: true, omitted: true},
+		{file: "vendor/c.go"},
+	}
+
+	for _, tt := range tests {
+		_, rendered, ok := strings.Cut(output, "```"+tt.file)
+		if ok != tt.rendered {
+			t.Fatalf("expected %s to be rendered: %v, got:\n%s", tt.file, tt.rendered, output)
+		}
+		rendered, _, _ = strings.Cut(rendered, "\n```")
+
+		if ok && strings.Contains(rendered, "lines omitted") != tt.omitted {
+			t.Errorf("expected Run in %s to be omitted: %v, got:\n%s", tt.file, tt.omitted, rendered)
+		}
+	}
+
+	_, err = RenderDirectory(dir, &RenderDirectoryOptions{
+		SpecMode:    "sometimes",
+		FileOptions: &RenderFileOptions{},
+	})
+	if err == nil {
+		t.Errorf("expected an unknown spec mode to be rejected")
+	}
+}
+
+func TestRenderedSpecRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.go"), "package main\n\nfunc Run() {\n\treturn\n}\n\nfunc Stop() {\n\treturn\n}\n")
+	writeTestFile(t, filepath.Join(dir, "b.go"), "package main\n\nfunc Wait() {\n\treturn\n}\n")
+	writeTestFile(t, filepath.Join(dir, "config.yaml"), "server:\n  host: localhost\n  tls:\n    cert: a.pem\n    key: a.key\n  limits:\n    rps: 10\n    burst: 20\n")
+	writeTestFile(t, filepath.Join(dir, "notes.txt"), "just some notes\n")
+
+	spec, err := ctxspec.ParseContextSpec("a.go Run\nconfig.yaml server.tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rendered []*ctxspec.FileContextSpec
+	fileOptions := &RenderFileOptions{
+		Outline:        true,
+		OutputMarkdown: true,
+		OnRendered: func(spec *ctxspec.FileContextSpec) {
+			rendered = append(rendered, spec)
+		},
+	}
+
+	output, err := RenderDirectory(dir, &RenderDirectoryOptions{
+		ContextSpec: spec,
+		SpecMode:    SpecOverlay,
+		FileOptions: fileOptions,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for _, fileSpec := range rendered {
+		lines = append(lines, fileSpec.String())
+	}
+
+	want := []string{"a.go Run", "b.go -", "config.yaml server.tls", "notes.txt"}
+	if !slices.Equal(lines, want) {
+		t.Fatalf("expected spec %q, got %q", want, lines)
+	}
+
+	replaySpec, err := ctxspec.ParseContextSpec(strings.Join(lines, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileOptions.OnRendered = nil
+	replayed, err := RenderDirectory(dir, &RenderDirectoryOptions{
+		ContextSpec: replaySpec,
+		FileOptions: fileOptions,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if replayed != output {
+		t.Errorf("expected the spec to render the same output, got:\n%s\n\ninstead of:\n%s", replayed, output)
+	}
+}
+
+func TestPages(t *testing.T) {
+	var text strings.Builder
+	text.WriteString("package main\n")
+	for _, name := range []string{"A", "B", "C"} {
+		fmt.Fprintf(&text, "\n// %s does things\nfunc %s() {\n\tprintln(1)\n\tprintln(2)\n}\n", name, name)
+	}
+
+	tests := []struct {
+		page   int
+		header string
+		first  string
+		last   string
+		footer string
+	}{
+		{page: 1, header: "(Lines 1-8 of 20, page 1 of 3)", first: "1  | package main", last: "8  | ", footer: "(12 lines below, continued on page 2 of 3)"},
+		{page: 2, header: "(Lines 9-14 of 20, page 2 of 3)", first: "9  | // B does things", last: "14 | ", footer: "(6 lines below, continued on page 3 of 3)"},
+		{page: 3, header: "(Lines 15-20 of 20, page 3 of 3)", first: "15 | // C does things", last: "20 | "},
+		{page: 9, header: "(Lines 15-20 of 20, page 3 of 3)", first: "15 | // C does things", last: "20 | "},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.page), func(t *testing.T) {
+			output, err := RenderFile("main.go", text.String(), &RenderFileOptions{
+				OutputMarkdown:  true,
+				ShowLineNumbers: true,
+				ShowPageInfo:    true,
+				PageSize:        10,
+				Page:            tt.page,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lines := strings.Split(output, "\n")
+			if !strings.HasSuffix(lines[0], tt.header) {
+				t.Fatalf("expected header %q, got:\n%s", tt.header, output)
+			}
+
+			var numbered []string
+			for _, line := range lines {
+				if line != "" && line[0] >= '0' && line[0] <= '9' {
+					numbered = append(numbered, line)
+				}
+			}
+
+			if numbered[0] != tt.first || numbered[len(numbered)-1] != tt.last {
+				t.Errorf("expected lines %q to %q, got:\n%s", tt.first, tt.last, output)
+			}
+
+			if tt.footer != "" && !strings.Contains(output, tt.footer) {
+				t.Errorf("expected footer %q, got:\n%s", tt.footer, output)
+			}
+		})
+	}
+
+	// A definition longer than a page gets a longer page to itself
+	output, err := RenderFile("main.go", text.String(), &RenderFileOptions{PageSize: 2, Page: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "// A does things\nfunc A() {\n\tprintln(1)\n\tprintln(2)\n}\n... (13 lines below") {
+		t.Errorf("expected all of A on page 2, got:\n%s", output)
+	}
+
+	// Definitions with no blank lines between them can still be split up
+	var adjacent strings.Builder
+	adjacent.WriteString("package main\n")
+	for _, name := range []string{"A", "B", "C", "D"} {
+		fmt.Fprintf(&adjacent, "// %s does things\nfunc %s() {\n}\n", name, name)
+	}
+
+	for page, header := range []string{
+		"(Lines 1-4 of 14, page 1 of 4)",
+		"(Lines 5-7 of 14, page 2 of 4)",
+		"(Lines 8-10 of 14, page 3 of 4)",
+		"(Lines 11-14 of 14, page 4 of 4)",
+	} {
+		name := string(rune('A' + page))
+		want := fmt.Sprintf("%s\n", header)
+		if page > 0 {
+			want += fmt.Sprintf("... (%d lines above) ...\n", 3*page+1)
+		} else {
+			want += "package main\n"
+		}
+		want += fmt.Sprintf("// %s does things\nfunc %s() {\n}\n", name, name)
+
+		output, err := RenderFile("main.go", adjacent.String(), &RenderFileOptions{
+			OutputMarkdown: true,
+			ShowPageInfo:   true,
+			PageSize:       4,
+			Page:           page + 1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(output, want) {
+			t.Errorf("expected page %d to contain:\n%s\ngot:\n%s", page+1, want, output)
+		}
+	}
+}
+
+func TestDirectoryPages(t *testing.T) {
+	dir := t.TempDir()
+	var long strings.Builder
+	long.WriteString("package main\n")
+	for i := range 12 {
+		fmt.Fprintf(&long, "\nfunc F%d() {\n\tprintln(%d)\n}\n", i, i)
+	}
+	writeTestFile(t, filepath.Join(dir, "a.go"), "package main\n\nfunc A() {\n}\n")
+	writeTestFile(t, filepath.Join(dir, "b.go"), long.String())
+	writeTestFile(t, filepath.Join(dir, "c.go"), "package main\n\nfunc C() {\n}\n")
+
+	render := func(page int) (string, error) {
+		return RenderDirectory(dir, &RenderDirectoryOptions{
+			Page:      page,
+			PageLines: 30,
+			FileOptions: &RenderFileOptions{
+				OutputMarkdown:  true,
+				ShowLineNumbers: true,
+				ShowPageInfo:    true,
+			},
+		})
+	}
+
+	var covered []string
+	for page := 1; ; page++ {
+		output, err := render(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(output, "\n")
+		if len(lines) > 30 {
+			t.Errorf("expected page %d to have at most 30 lines, got %d:\n%s", page, len(lines), output)
+		}
+
+		var pages int
+		if _, err := fmt.Sscanf(lines[0], "Page %d of %d, covering:", new(int), &pages); err != nil {
+			t.Fatalf("expected a page title, got:\n%s", output)
+		}
+
+		for _, line := range lines[1:] {
+			if line == "" {
+				break
+			}
+			covered = append(covered, line)
+		}
+
+		if page == pages {
+			if strings.Contains(output, "continued on page") {
+				t.Errorf("expected no next page after the last, got:\n%s", output)
+			}
+			break
+		}
+
+		if !strings.HasSuffix(output, fmt.Sprintf("... (continued on page %d of %d) ...", page+1, pages)) {
+			t.Errorf("expected page %d to point to the next, got:\n%s", page, output)
+		}
+	}
+
+	want := []string{
+		"- a.go (lines 1-5 of 5)",
+		"- b.go (lines 1-18 of 50)",
+		"- b.go (lines 19-38 of 50)",
+		"- b.go (lines 39-50 of 50)",
+		"- c.go (lines 1-5 of 5)",
+	}
+	if !slices.Equal(covered, want) {
+		t.Errorf("expected pages to cover %q, got %q", want, covered)
+	}
+
+	if _, err := render(99); err == nil {
+		t.Errorf("expected a page past the end to be an error")
+	}
+}
+
+func TestRenderView(t *testing.T) {
+	goText := `package server
+
+type Server struct {
+	name string
+}
+
+func (s *Server) Handle() {
+	a := 1
+	b := 2
+	c := 3
+	println(a, b, c)
+}
+`
+	pyText := `class Animal:
+    def speak(self):
+        a = 1
+        b = 2
+        return a + b
+`
+
+	tests := []struct {
+		name     string
+		filename string
+		text     string
+		view     ViewOptions
+		want     []string
+	}{
+		{
+			name:     "around",
+			filename: "server.go",
+			text:     goText,
+			view:     ViewOptions{Around: 10, Context: 1},
+			want: []string{
+				"```server.go (Lines 9-11 of 13)",
+				"   | ... (2 lines above) ...",
+				"3  | type Server struct {",
+				"   | ... (3 lines) ...",
+				"7  | func (s *Server) Handle() {",
+				"   | ... (1 line) ...",
+				"9  | \tb := 2",
+				"10 | \tc := 3",
+				"11 | \tprintln(a, b, c)",
+				"   | ... (2 lines below) ...",
+				"```",
+			},
+		},
+		{
+			name:     "single line",
+			filename: "server.go",
+			text:     goText,
+			view:     ViewOptions{Around: 1},
+			want: []string{
+				"```server.go (Line 1 of 13)",
+				"1  | package server",
+				"   | ... (12 lines below) ...",
+				"```",
+			},
+		},
+		{
+			name:     "symbol",
+			filename: "animal.py",
+			text:     pyText,
+			view:     ViewOptions{Symbol: "Animal.speak"},
+			want: []string{
+				"```animal.py (Lines 2-5 of 6)",
+				"1 | class Animal:",
+				"2 |     def speak(self):",
+				"3 |         a = 1",
+				"4 |         b = 2",
+				"5 |         return a + b",
+				"  | ... (1 line below) ...",
+				"```",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := RenderView(tt.filename, tt.text, &tt.view, &RenderFileOptions{
+				OutputMarkdown:  true,
+				ShowLineNumbers: true,
+				ShowPageInfo:    true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := strings.Join(tt.want, "\n"); output != want {
+				t.Errorf("expected:\n%s\n\ngot:\n%s", want, output)
+			}
+		})
+	}
+
+	_, err := RenderView("server.go", goText, &ViewOptions{Symbol: "Handel"}, &RenderFileOptions{})
+	if err == nil || !strings.Contains(err.Error(), "Did you mean Server.Handle?") {
+		t.Errorf("expected an unknown symbol to suggest Server.Handle, got %v", err)
+	}
+}
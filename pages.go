@@ -0,0 +1,332 @@
+package llmcat
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/everestmz/llmcat/treesym"
+)
+
+// pageStarts splits lines into pages of at most pageSize lines, and returns
+// the index of the first line of each page. Pages only break between top-level
+// definitions, along with any comments and attributes directly above them, so
+// a definition is never split. One that's longer than pageSize gets a longer
+// page to itself
+func pageStarts(lines []string, defs []*treesym.Node, pageSize int) []int {
+	starts := []int{0}
+	if pageSize < 1 {
+		return starts
+	}
+
+	topLevel := topLevelDefinitions(defs)
+	slices.SortFunc(topLevel, func(a, b *treesym.Node) int {
+		return int(a.StartPoint.Row) - int(b.StartPoint.Row)
+	})
+
+	// A page can't start inside a definition, only on its first line
+	inside := make([]bool, len(lines))
+	previousEnd := -1
+	for _, def := range topLevel {
+		start, end := int(def.StartPoint.Row), min(int(def.EndPoint.Row), len(lines)-1)
+
+		// Keep doc comments and attributes directly above the definition with
+		// it, without reaching back into the definition before it
+		for start > previousEnd+1 && isCommentOrAttribute(lines[start-1]) {
+			start--
+		}
+		previousEnd = max(previousEnd, end)
+
+		for i := start + 1; i <= end; i++ {
+			inside[i] = true
+		}
+	}
+
+	canBreak := func(i int) bool {
+		return !inside[i]
+	}
+
+	for start := 0; start+pageSize < len(lines); {
+		next := -1
+		for i := start + pageSize; i > start; i-- {
+			if canBreak(i) {
+				next = i
+				break
+			}
+		}
+
+		if next < 0 {
+			for i := start + pageSize + 1; i < len(lines); i++ {
+				if canBreak(i) {
+					next = i
+					break
+				}
+			}
+		}
+
+		if next < 0 {
+			break
+		}
+
+		starts = append(starts, next)
+		start = next
+	}
+
+	return starts
+}
+
+// commentAndAttributePrefixes start the lines that can sit directly above a
+// definition and belong to it, in the languages we outline
+var commentAndAttributePrefixes = []string{"//", "/*", "*", "#", "--", ";", "@", "["}
+
+// isCommentOrAttribute guesses whether line is a comment or an attribute,
+// like a doc comment, a decorator or a #[derive]
+func isCommentOrAttribute(line string) bool {
+	line = strings.TrimSpace(line)
+	for _, prefix := range commentAndAttributePrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// topLevelDefinitions are the definitions that aren't inside any other
+func topLevelDefinitions(defs []*treesym.Node) []*treesym.Node {
+	var topLevel []*treesym.Node
+	for _, def := range defs {
+		nested := false
+		for _, other := range defs {
+			if other == def || other.StartPoint.Row == def.StartPoint.Row && other.EndPoint.Row == def.EndPoint.Row {
+				continue
+			}
+
+			if other.StartPoint.Row <= def.StartPoint.Row && def.EndPoint.Row <= other.EndPoint.Row {
+				nested = true
+				break
+			}
+		}
+
+		if !nested {
+			topLevel = append(topLevel, def)
+		}
+	}
+
+	return topLevel
+}
+
+// estimateTokens guesses how many tokens text is, at about four characters
+// to a token, which is close enough for code with most tokenizers
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// filePageStarts is pageStarts for the file filename, snapping to its
+// definitions if it can be parsed
+func filePageStarts(filename, text string, options *RenderFileOptions, pageSize int) []int {
+	var defs []*treesym.Node
+	if psf, err := parseFile(filename, text, options); err == nil {
+		defs = psf.Definitions
+	}
+
+	return pageStarts(strings.Split(text, "\n"), defs, pageSize)
+}
+
+// renderedFile is a file rendered as part of a directory, kept until all of
+// them are joined together
+type renderedFile struct {
+	relPath string
+	// path is where the file lives on disk, and may be empty
+	path     string
+	text     string
+	rendered string
+	warnings []Warning
+}
+
+// pageEntry is a file, or part of one, on a directory page
+type pageEntry struct {
+	relPath  string
+	rendered string
+	warnings []Warning
+	// The lines of the file that are rendered, starting from 1
+	firstLine, lastLine, totalLines int
+}
+
+func (e *pageEntry) String() string {
+	return fmt.Sprintf("- %s (%s of %d)", e.relPath, strings.ToLower(lineRange(e.firstLine, e.lastLine)), e.totalLines)
+}
+
+// pageCost is how much of a directory page something takes up
+type pageCost struct {
+	lines, tokens int
+}
+
+func costOf(text string) pageCost {
+	return pageCost{lines: strings.Count(text, "\n") + 1, tokens: estimateTokens(text)}
+}
+
+func (c pageCost) add(other pageCost) pageCost {
+	return pageCost{lines: c.lines + other.lines, tokens: c.tokens + other.tokens}
+}
+
+// fits reports whether c is within the page limits in options
+func (c pageCost) fits(options *RenderDirectoryOptions) bool {
+	if options.PageLines > 0 && c.lines > options.PageLines {
+		return false
+	}
+
+	return options.PageTokens == 0 || c.tokens <= options.PageTokens
+}
+
+// pageFrame is the cost of a page's title and footer, around its files
+var pageFrame = costOf("Page 10 of 10, covering:\n\n\n... (continued on page 10 of 10) ...")
+
+// joinDirectory puts the rendered files together, or returns just the page
+// asked for if the directory is paginated. before and after are warnings that
+// aren't about any one file, which are summarized before and after the files'
+// own warnings, on the first and last pages
+func (rdo *RenderDirectoryOptions) joinDirectory(files []*renderedFile, before, after []Warning) (string, error) {
+	if rdo.Page == 0 {
+		var renderings []string
+		warnings := slices.Clone(before)
+		for _, f := range files {
+			renderings = append(renderings, f.rendered)
+			warnings = append(warnings, f.warnings...)
+		}
+
+		return joinRenderedFiles(renderings, append(warnings, after...)), nil
+	}
+
+	var entries []*pageEntry
+	for _, f := range files {
+		fileEntries, err := rdo.pageEntries(f)
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	// Fill up each page in order, so that the same files always give the same
+	// pages. Anything too big for a page on its own still gets one
+	pages := [][]*pageEntry{nil}
+	used := pageFrame
+	for _, entry := range entries {
+		cost := entryCost(entry)
+		last := len(pages) - 1
+		if len(pages[last]) > 0 && !used.add(cost).fits(rdo) {
+			pages = append(pages, nil)
+			last++
+			used = pageFrame
+		}
+
+		pages[last] = append(pages[last], entry)
+		used = used.add(cost)
+	}
+
+	if rdo.Page > len(pages) {
+		return "", fmt.Errorf("page %d is past the end, there are only %d pages", rdo.Page, len(pages))
+	}
+
+	page := pages[rdo.Page-1]
+	index := []string{fmt.Sprintf("Page %d of %d, covering:", rdo.Page, len(pages))}
+	var renderings []string
+	var warnings []Warning
+	if rdo.Page == 1 {
+		warnings = append(warnings, before...)
+	}
+	for _, entry := range page {
+		index = append(index, entry.String())
+		renderings = append(renderings, entry.rendered)
+		warnings = append(warnings, entry.warnings...)
+	}
+	if rdo.Page == len(pages) {
+		warnings = append(warnings, after...)
+	}
+
+	output := strings.Join(index, "\n") + "\n\n" + joinRenderedFiles(renderings, warnings)
+	if rdo.Page < len(pages) {
+		output += fmt.Sprintf("\n\n... (continued on page %d of %d) ...", rdo.Page+1, len(pages))
+	}
+
+	return output, nil
+}
+
+// entryCost is how much of a page entry takes up, including its line in the
+// page's index and the blank line after it
+func entryCost(entry *pageEntry) pageCost {
+	return costOf(entry.String() + "\n" + entry.rendered + "\n")
+}
+
+// pageEntries is f as a single page entry, or split into several if it's too
+// big to fit on a page by itself. Files are split between their top-level
+// definitions, like pages within a file
+func (rdo *RenderDirectoryOptions) pageEntries(f *renderedFile) ([]*pageEntry, error) {
+	totalLines := strings.Count(f.text, "\n") + 1
+	whole := &pageEntry{
+		relPath:    f.relPath,
+		rendered:   f.rendered,
+		warnings:   f.warnings,
+		firstLine:  1,
+		lastLine:   totalLines,
+		totalLines: totalLines,
+	}
+
+	plan := rdo.planFile(f.relPath)
+	if pageFrame.add(entryCost(whole)).fits(rdo) || plan.Mode == RenderTree {
+		return []*pageEntry{whole}, nil
+	}
+
+	// Leave room for the file's header, markers and warnings around each part
+	const partFrame = 6
+	renderedLines := strings.Count(f.rendered, "\n") + 1
+	pageSize := totalLines
+	if rdo.PageLines > 0 {
+		pageSize = rdo.PageLines - pageFrame.lines - partFrame
+	}
+	if rdo.PageTokens > 0 {
+		tokensPerLine := max(estimateTokens(f.rendered)/renderedLines, 1)
+		pageSize = min(pageSize, (rdo.PageTokens-pageFrame.tokens)/tokensPerLine-partFrame)
+	}
+
+	// The file's warnings were already reported when it was first rendered
+	format := *plan.Format
+	format.OnWarning = nil
+	format.OnRendered = nil
+
+	starts := filePageStarts(f.relPath, f.text, &format, max(pageSize, 1))
+
+	var entries []*pageEntry
+	for i, start := range starts {
+		end := totalLines
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+
+		part := rdo.planFile(f.relPath)
+		part.Format = &format
+		part.Page = 0
+		part.StartLine = start + 1
+		part.PageSize = end - start
+
+		rendered, _, err := renderFile(f.relPath, f.path, f.text, part)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering file %s: %w", f.relPath, err)
+		}
+
+		entry := &pageEntry{
+			relPath:    f.relPath,
+			rendered:   rendered,
+			firstLine:  start + 1,
+			lastLine:   end,
+			totalLines: totalLines,
+		}
+		if i == 0 {
+			entry.warnings = f.warnings
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -1,18 +1,153 @@
 package llmcat
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/everestmz/llmcat/git"
 )
 
-func RenderGitRepo(url string, options *RenderDirectoryOptions) (string, error) {
-	gitBinary, err := exec.LookPath("git")
+type RemoteRepoOptions struct {
+	// Ref is the branch, tag or full commit hash to render. Overrides any ref
+	// given in the repo reference. Defaults to the remote's HEAD
+	Ref string `json:"ref"`
+	// Subdir is the subdirectory of the repo to render. Overrides any subdir
+	// given in the repo reference. Only this subdirectory is checked out
+	Subdir string `json:"subdir"`
+	// AuthToken is sent as the password for https remotes, for private repos
+	// that aren't covered by a git credential helper
+	AuthToken string `json:"auth_token"`
+	// Cache keeps a blobless bare mirror of every remote in CacheDir, which is
+	// fetched incrementally and rendered from directly, rather than cloning
+	// each time
+	Cache    bool   `json:"cache"`
+	CacheDir string `json:"cache_dir"`
+	// CacheTTL is how long a mirror is used before it's fetched again
+	CacheTTL time.Duration `json:"cache_ttl"`
+	// Refresh fetches the mirror even if it's younger than CacheTTL
+	Refresh bool `json:"refresh"`
+}
+
+func (rro *RemoteRepoOptions) SetDefaults() error {
+	if rro.CacheDir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return err
+		}
+
+		rro.CacheDir = filepath.Join(userCacheDir, "llmcat", "repos")
+	}
+
+	if rro.CacheTTL == 0 {
+		rro.CacheTTL = time.Hour
+	}
+
+	return nil
+}
+
+// RemoteRepo is a parsed reference to a repo on a git remote
+type RemoteRepo struct {
+	URL    string
+	Ref    string
+	Subdir string
+}
+
+var (
+	remoteURLPrefixes = []string{"https://", "http://", "ssh://", "git://", "file://", "git@"}
+	githubShorthand   = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+)
+
+// IsRemoteRepo returns true if path refers to a git remote rather than a local
+// file or directory. Remotes are URLs, scp-style git@host:path references, and
+// paths ending in .git that don't exist locally. Any of these can be followed
+// by #ref:subdir. GitHub owner/repo shorthand looks just like a relative path,
+// so it's only a remote if it doesn't exist locally, and either has a #ref or
+// wantRemote is set because the user asked for a remote
+func IsRemoteRepo(path string, wantRemote bool) bool {
+	url, _, hasFragment := strings.Cut(path, "#")
+
+	for _, prefix := range remoteURLPrefixes {
+		if strings.HasPrefix(url, prefix) {
+			return true
+		}
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return false
+	}
+
+	if strings.HasSuffix(url, ".git") {
+		return true
+	}
+
+	return githubShorthand.MatchString(url) && (hasFragment || wantRemote)
+}
+
+// ParseRemoteRepo parses a repo reference of the form url#ref:subdir, where
+// ref and subdir are both optional (url#ref, url#:subdir). GitHub owner/repo
+// shorthand is expanded to an https URL
+func ParseRemoteRepo(spec string) (*RemoteRepo, error) {
+	url, fragment, _ := strings.Cut(spec, "#")
+	if url == "" {
+		return nil, fmt.Errorf("invalid repo reference %q: missing URL", spec)
+	}
+
+	repo := &RemoteRepo{
+		URL: url,
+	}
+
+	repo.Ref, repo.Subdir, _ = strings.Cut(fragment, ":")
+
+	if githubShorthand.MatchString(url) && !strings.HasSuffix(url, ".git") {
+		repo.URL = fmt.Sprintf("https://github.com/%s.git", url)
+	}
+
+	if repo.Subdir != "" {
+		repo.Subdir = filepath.Clean(repo.Subdir)
+		if filepath.IsAbs(repo.Subdir) || strings.HasPrefix(repo.Subdir, "..") {
+			return nil, fmt.Errorf("invalid repo reference %q: subdirectory must be inside the repo", spec)
+		}
+	}
+
+	return repo, nil
+}
+
+// RenderGitRepo clones the repo referenced by spec (see ParseRemoteRepo) and
+// renders it. Only the requested ref is fetched, and only the requested
+// subdirectory is checked out, so large monorepos aren't downloaded in full.
+// With remoteOptions.Cache, it renders from a cached mirror instead
+func RenderGitRepo(spec string, remoteOptions *RemoteRepoOptions, options *RenderDirectoryOptions) (string, error) {
+	if remoteOptions == nil {
+		remoteOptions = &RemoteRepoOptions{}
+	}
+
+	repo, err := ParseRemoteRepo(spec)
 	if err != nil {
 		return "", err
 	}
 
+	if remoteOptions.Ref != "" {
+		repo.Ref = remoteOptions.Ref
+	}
+
+	if remoteOptions.Subdir != "" {
+		repo.Subdir = filepath.Clean(remoteOptions.Subdir)
+	}
+
+	if remoteOptions.Cache {
+		return renderFromMirror(repo, remoteOptions, options)
+	}
+
 	tempDir, err := os.MkdirTemp(os.TempDir(), "llmcat-clone-*")
 	if err != nil {
 		return "", err
@@ -21,11 +156,333 @@ func RenderGitRepo(url string, options *RenderDirectoryOptions) (string, error)
 
 	repoDir := filepath.Join(tempDir, "src")
 
-	cloneCommand := exec.Command(gitBinary, "clone", "--depth", "1", url, repoDir)
-	out, err := cloneCommand.CombinedOutput()
+	err = cloneRemoteRepo(repo, repoDir, remoteOptions.AuthToken)
+	if err != nil {
+		return "", err
+	}
+
+	renderDir := filepath.Join(repoDir, repo.Subdir)
+	info, err := os.Stat(renderDir)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("subdirectory %s not found in %s", repo.Subdir, repo.URL)
+	}
+
+	return RenderDirectory(renderDir, options)
+}
+
+// cloneRemoteRepo checks out repo into dir. It's a shallow, blobless fetch of
+// just the requested ref, and a sparse checkout of just the requested subdir,
+// so git only downloads the blobs we're actually going to render
+func cloneRemoteRepo(repo *RemoteRepo, dir, authToken string) error {
+	runGit := func(args ...string) error {
+		return runGitCommand(dir, repo.URL, authToken, args...)
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	err = runGit("init", "-q")
+	if err != nil {
+		return err
+	}
+
+	err = runGit("remote", "add", "origin", repo.URL)
+	if err != nil {
+		return err
+	}
+
+	ref := repo.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	err = runGit("fetch", "-q", "--depth", "1", "--filter=blob:none", "origin", ref)
+	if err != nil {
+		return err
+	}
+
+	if repo.Subdir != "" && repo.Subdir != "." {
+		err = runGit("sparse-checkout", "set", "--cone", filepath.ToSlash(repo.Subdir))
+		if err != nil {
+			return err
+		}
+	}
+
+	return runGit("checkout", "-q", "FETCH_HEAD")
+}
+
+// runGitCommand runs git in dir, against the remote at url. If authToken is
+// set and the remote is https, it's sent as the password
+func runGitCommand(dir, url, authToken string, args ...string) error {
+	_, err := gitOutput(dir, url, authToken, nil, args...)
+	return err
+}
+
+// gitOutput runs git like runGitCommand, reading stdin if it's set, and
+// returns what it wrote to stdout
+func gitOutput(dir, url, authToken string, stdin io.Reader, args ...string) (string, error) {
+	cmd, err := gitCommand(dir, url, authToken, args...)
+	if err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		// Only name the subcommand, since the arguments may contain credentials
+		return "", fmt.Errorf("git %s failed for %s: %w: %s", args[0], url, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// gitCommand builds the command for runGitCommand. The auth token goes in the
+// environment rather than the arguments, so other users can't see it in ps
+func gitCommand(dir, url, authToken string, args ...string) (*exec.Cmd, error) {
+	gitBinary, err := exec.LookPath("git")
 	if err != nil {
-		return "", fmt.Errorf(string(out))
+		return nil, err
+	}
+
+	cmd := exec.Command(gitBinary, args...)
+	cmd.Dir = dir
+	// Never block waiting for a password on a terminal the caller can't see
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	if authToken != "" && strings.HasPrefix(url, "https://") {
+		credentials := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + authToken))
+
+		// Add to any config the caller already passes this way, rather than
+		// replacing it
+		count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+		cmd.Env = append(cmd.Env,
+			fmt.Sprintf("GIT_CONFIG_COUNT=%d", count+1),
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=http.extraHeader", count),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=Authorization: Basic %s", count, credentials),
+		)
+	}
+
+	return cmd, nil
+}
+
+// mirrorFetchedMarker is touched inside a mirror every time it's fetched, so
+// we know how stale it is
+const mirrorFetchedMarker = "llmcat-fetched"
+
+// mirrorPath returns where the mirror of url lives in cacheDir. The URL is
+// hashed so any URL maps to a safe directory name
+func mirrorPath(cacheDir, url string) string {
+	hash := sha256.Sum256([]byte(url))
+	name := strings.TrimSuffix(filepath.Base(strings.TrimRight(url, "/")), ".git")
+
+	return filepath.Join(cacheDir, fmt.Sprintf("%s-%x.git", name, hash[:8]))
+}
+
+// updateMirror makes sure there's an up to date bare mirror of repo in the
+// cache, and returns its path and whether it was fetched during this call.
+// Mirrors have every commit and tree, but no blobs until they're rendered, so
+// large remotes aren't downloaded in full
+func updateMirror(repo *RemoteRepo, options *RemoteRepoOptions) (string, bool, error) {
+	mirrorDir := mirrorPath(options.CacheDir, repo.URL)
+	marker := filepath.Join(mirrorDir, mirrorFetchedMarker)
+
+	info, err := os.Stat(marker)
+	switch {
+	case os.IsNotExist(err):
+		err = os.MkdirAll(options.CacheDir, 0755)
+		if err != nil {
+			return "", false, err
+		}
+
+		// Clone next to the final location and move it into place, so an
+		// interrupted clone never leaves a broken mirror behind, and a
+		// concurrent llmcat never sees a half-written one
+		tempDir, err := os.MkdirTemp(options.CacheDir, "clone-*")
+		if err != nil {
+			return "", false, err
+		}
+		defer os.RemoveAll(tempDir)
+
+		err = runGitCommand(tempDir, repo.URL, options.AuthToken, "clone", "-q", "--mirror", "--filter=blob:none", repo.URL, "mirror.git")
+		if err != nil {
+			return "", false, err
+		}
+
+		cloneDir := filepath.Join(tempDir, "mirror.git")
+		err = os.WriteFile(filepath.Join(cloneDir, mirrorFetchedMarker), nil, 0644)
+		if err != nil {
+			return "", false, err
+		}
+
+		err = installMirror(cloneDir, mirrorDir, options.CacheDir)
+		if err != nil {
+			return "", false, err
+		}
+
+		return mirrorDir, true, nil
+	case err != nil:
+		return "", false, err
+	case options.Refresh || time.Since(info.ModTime()) > options.CacheTTL:
+		err = runGitCommand(mirrorDir, repo.URL, options.AuthToken, "fetch", "-q", "--prune", "origin")
+		if err != nil {
+			return "", false, err
+		}
+	default:
+		return mirrorDir, false, nil
+	}
+
+	err = os.WriteFile(marker, nil, 0644)
+	if err != nil {
+		return "", false, err
+	}
+
+	return mirrorDir, true, nil
+}
+
+// installMirror moves the finished clone at cloneDir to mirrorDir. Renames are
+// atomic, so if another process installs its own clone first, theirs is kept
+// and ours is thrown away. A directory left at mirrorDir without a fetched
+// marker is from an older llmcat, and is moved aside and replaced
+func installMirror(cloneDir, mirrorDir, cacheDir string) error {
+	err := os.Rename(cloneDir, mirrorDir)
+	if err == nil {
+		return nil
+	}
+
+	_, statErr := os.Stat(filepath.Join(mirrorDir, mirrorFetchedMarker))
+	if statErr == nil {
+		return nil
+	}
+
+	brokenDir, tempErr := os.MkdirTemp(cacheDir, "broken-*")
+	if tempErr != nil {
+		return err
+	}
+	defer os.RemoveAll(brokenDir)
+
+	// If someone else already moved it aside, there's nothing left to move
+	moveErr := os.Rename(mirrorDir, filepath.Join(brokenDir, "mirror.git"))
+	if moveErr != nil && !os.IsNotExist(moveErr) {
+		return err
+	}
+
+	err = os.Rename(cloneDir, mirrorDir)
+	if err != nil {
+		if _, statErr := os.Stat(filepath.Join(mirrorDir, mirrorFetchedMarker)); statErr == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// renderFromMirror renders repo at the requested ref straight from the object
+// store of its cached mirror, without checking anything out
+func renderFromMirror(repo *RemoteRepo, remoteOptions *RemoteRepoOptions, options *RenderDirectoryOptions) (string, error) {
+	err := remoteOptions.SetDefaults()
+	if err != nil {
+		return "", err
+	}
+
+	mirrorDir, fetched, err := updateMirror(repo, remoteOptions)
+	if err != nil {
+		return "", err
+	}
+
+	ref := repo.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	gitRepo, err := git.NewBareRepo(mirrorDir)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := gitRepo.ResolveRevision(ref)
+	if err != nil && !fetched {
+		// The ref may be newer than our mirror, so fetch once before giving up
+		err = runGitCommand(mirrorDir, repo.URL, remoteOptions.AuthToken, "fetch", "-q", "--prune", "origin")
+		if err != nil {
+			return "", err
+		}
+
+		err = os.WriteFile(filepath.Join(mirrorDir, mirrorFetchedMarker), nil, 0644)
+		if err != nil {
+			return "", err
+		}
+
+		gitRepo, err = git.NewBareRepo(mirrorDir)
+		if err != nil {
+			return "", err
+		}
+
+		hash, err = gitRepo.ResolveRevision(ref)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", repo.URL, err)
+	}
+
+	err = fetchMirrorBlobs(mirrorDir, repo, hash, remoteOptions.AuthToken)
+	if err != nil {
+		return "", err
+	}
+
+	// Open it again to see the blobs, which go-git doesn't notice arriving
+	gitRepo, err = git.NewBareRepo(mirrorDir)
+	if err != nil {
+		return "", err
+	}
+
+	return renderGitTree(gitRepo, hash, repo.Subdir, options)
+}
+
+// fetchMirrorBlobs fetches the blobs in repo's subdir at rev that the mirror
+// doesn't have yet, all at once. git would fetch them one at a time as they're
+// read, but go-git can't fetch them at all
+func fetchMirrorBlobs(mirrorDir string, repo *RemoteRepo, rev, authToken string) error {
+	tree := rev + "^{tree}"
+	if subdir := filepath.ToSlash(repo.Subdir); subdir != "" && subdir != "." {
+		tree = rev + ":" + subdir
+	}
+
+	// Listing what's missing doesn't fetch it
+	out, err := gitOutput(mirrorDir, repo.URL, authToken, nil, "rev-list", "--objects", "--missing=print", tree)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, line := range strings.Split(out, "\n") {
+		if hash, ok := strings.CutPrefix(line, "?"); ok {
+			missing = append(missing, hash)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// The same fetch git does for missing objects itself
+	_, err = gitOutput(mirrorDir, repo.URL, authToken, strings.NewReader(strings.Join(missing, "\n")+"\n"),
+		"fetch", "-q", "--no-tags", "--no-write-fetch-head", "--recurse-submodules=no", "--filter=blob:none", "--stdin", "origin")
+
+	return err
+}
+
+// renderGitTree renders subdir of repo at rev like RenderDirectory would if it
+// were checked out, reading every file from the object store
+func renderGitTree(repo *git.Repo, rev, subdir string, options *RenderDirectoryOptions) (string, error) {
+	fsys, err := repo.TreeFS(rev, subdir)
+	if err != nil {
+		return "", err
 	}
 
-	return RenderDirectory(repoDir, options)
+	return renderDirectory(&directory{name: filepath.Join(repo.Root(), subdir), fsys: fsys}, options)
 }
@@ -0,0 +1,420 @@
+package llmcat
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/everestmz/llmcat/ctxspec"
+	"github.com/everestmz/llmcat/git"
+)
+
+func TestParseRemoteRepo(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    RemoteRepo
+		wantErr bool
+	}{
+		{
+			spec: "https://github.com/everestmz/llmcat.git",
+			want: RemoteRepo{URL: "https://github.com/everestmz/llmcat.git"},
+		},
+		{
+			spec: "https://github.com/everestmz/llmcat.git#main",
+			want: RemoteRepo{URL: "https://github.com/everestmz/llmcat.git", Ref: "main"},
+		},
+		{
+			spec: "git@github.com:everestmz/llmcat.git#v1.0.0:treesym/tags",
+			want: RemoteRepo{URL: "git@github.com:everestmz/llmcat.git", Ref: "v1.0.0", Subdir: "treesym/tags"},
+		},
+		{
+			spec: "everestmz/llmcat#:ctxspec",
+			want: RemoteRepo{URL: "https://github.com/everestmz/llmcat.git", Subdir: "ctxspec"},
+		},
+		{
+			spec:    "#main",
+			wantErr: true,
+		},
+		{
+			spec:    "everestmz/llmcat#main:../outside",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseRemoteRepo(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRemoteRepo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && *got != tt.want {
+				t.Fatalf("ParseRemoteRepo() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRemoteRepo(t *testing.T) {
+	tests := []struct {
+		path       string
+		wantRemote bool
+		want       bool
+	}{
+		{path: "https://github.com/everestmz/llmcat", want: true},
+		{path: "git@github.com:everestmz/llmcat.git", want: true},
+		{path: "file:///tmp/repo.git#main", want: true},
+		{path: "../repo.git", want: true},
+		{path: "everestmz/llmcat#main", want: true},
+		{path: "everestmz/llmcat", wantRemote: true, want: true},
+		// Without asking for a remote, this is just a missing local path
+		{path: "everestmz/llmcat"},
+		// These exist locally, so they're never remotes
+		{path: "."},
+		{path: "treesym/tags", wantRemote: true},
+		{path: "llmcat.go", wantRemote: true},
+	}
+
+	for _, tt := range tests {
+		if got := IsRemoteRepo(tt.path, tt.wantRemote); got != tt.want {
+			t.Errorf("IsRemoteRepo(%q, %v) = %v, want %v", tt.path, tt.wantRemote, got, tt.want)
+		}
+	}
+}
+
+func TestGitCommandAuthToken(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	cmd, err := gitCommand(t.TempDir(), "https://example.com/repo.git", "s3cret", "config", "--get", "http.extraHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(strings.Join(cmd.Args, " "), "s3cret") || strings.Contains(strings.Join(cmd.Args, " "), "Authorization") {
+		t.Errorf("expected the token to stay out of the arguments, got %v", cmd.Args)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("x-access-token:s3cret"))
+	if got := strings.TrimSpace(string(out)); got != want {
+		t.Errorf("expected git to see header %q, got %q", want, got)
+	}
+
+	// Tokens are only ever sent over https
+	cmd, err = gitCommand(t.TempDir(), "http://example.com/repo.git", "s3cret", "config", "--get", "http.extraHeader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := cmd.Output(); err == nil {
+		t.Errorf("expected no header for an http remote, got %q", out)
+	}
+}
+
+func runTestGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-c", "user.name=llmcat", "-c", "user.email=llmcat@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+func writeTestFile(t *testing.T, path, text string) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(path, []byte(text), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestRemote creates a bare repo with two commits, tagging the first as v1,
+// and returns its file:// URL and the worktree it was cloned from
+func newTestRemote(t *testing.T) (string, string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	workDir := filepath.Join(t.TempDir(), "work")
+	runTestGit(t, "", "init", "-q", "-b", "main", workDir)
+
+	writeTestFile(t, filepath.Join(workDir, "api", "server.go"), "package api\n\nfunc Serve() {}\n")
+	writeTestFile(t, filepath.Join(workDir, "docs", "README.md"), "# Docs\n")
+	runTestGit(t, workDir, "add", "-A")
+	runTestGit(t, workDir, "commit", "-q", "-m", "first")
+	runTestGit(t, workDir, "tag", "v1")
+
+	writeTestFile(t, filepath.Join(workDir, "api", "server.go"), "package api\n\nfunc ServeV2() {}\n")
+	runTestGit(t, workDir, "commit", "-q", "-am", "second")
+
+	bareDir := filepath.Join(t.TempDir(), "remote.git")
+	runTestGit(t, "", "clone", "-q", "--bare", workDir, bareDir)
+	// Like GitHub, so blobless clones work
+	runTestGit(t, bareDir, "config", "uploadpack.allowFilter", "true")
+
+	return "file://" + bareDir, workDir
+}
+
+func TestRenderGitRepo(t *testing.T) {
+	remote, _ := newTestRemote(t)
+
+	newOptions := func() *RenderDirectoryOptions {
+		return &RenderDirectoryOptions{
+			FileOptions: &RenderFileOptions{OutputMarkdown: true},
+			IgnoreGlobs: []string{"**/.git/**"},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		spec          string
+		remoteOptions *RemoteRepoOptions
+		contains      []string
+		excludes      []string
+		wantErr       bool
+	}{
+		{
+			name:     "default branch",
+			spec:     remote,
+			contains: []string{"ServeV2", "# Docs"},
+		},
+		{
+			name:     "tag and subdirectory",
+			spec:     remote + "#v1:api",
+			contains: []string{"server.go", "func Serve()"},
+			excludes: []string{"ServeV2", "# Docs"},
+		},
+		{
+			name:          "options override the reference",
+			spec:          remote + "#v1:api",
+			remoteOptions: &RemoteRepoOptions{Ref: "main", Subdir: "docs"},
+			contains:      []string{"# Docs"},
+			excludes:      []string{"server.go"},
+		},
+		{
+			name:    "missing ref",
+			spec:    remote + "#nope",
+			wantErr: true,
+		},
+		{
+			name:    "missing subdirectory",
+			spec:    remote + "#:nope",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := RenderGitRepo(tt.spec, tt.remoteOptions, newOptions())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderGitRepo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, s := range tt.contains {
+				if !strings.Contains(output, s) {
+					t.Errorf("expected output to contain %q, got:\n%s", s, output)
+				}
+			}
+
+			for _, s := range tt.excludes {
+				if strings.Contains(output, s) {
+					t.Errorf("expected output not to contain %q, got:\n%s", s, output)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderGitRepoCache(t *testing.T) {
+	remote, workDir := newTestRemote(t)
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	render := func(spec string, refresh bool) string {
+		t.Helper()
+
+		output, err := RenderGitRepo(spec, &RemoteRepoOptions{
+			Cache:    true,
+			CacheDir: cacheDir,
+			CacheTTL: time.Hour,
+			Refresh:  refresh,
+		}, &RenderDirectoryOptions{
+			FileOptions: &RenderFileOptions{OutputMarkdown: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return output
+	}
+
+	output := render(remote+"#:api", false)
+	if !strings.Contains(output, "ServeV2") || strings.Contains(output, "README.md") {
+		t.Fatalf("unexpected output from new mirror:\n%s", output)
+	}
+
+	// Only the blobs that were rendered are downloaded
+	mirrorDir := mirrorPath(cacheDir, remote)
+	if missing := runTestGit(t, mirrorDir, "rev-list", "--objects", "--missing=print", "HEAD:docs"); !strings.Contains(missing, "?") {
+		t.Errorf("expected the mirror not to have docs/README.md, got:\n%s", missing)
+	}
+
+	if output := render(remote+"#v1:api", false); !strings.Contains(output, "func Serve()") {
+		t.Fatalf("expected v1 to be rendered from the mirror, got:\n%s", output)
+	}
+
+	// Push a new commit upstream
+	writeTestFile(t, filepath.Join(workDir, "api", "server.go"), "package api\n\nfunc ServeV3() {}\n")
+	runTestGit(t, workDir, "commit", "-q", "-am", "third")
+	runTestGit(t, workDir, "push", "-q", strings.TrimPrefix(remote, "file://"), "main")
+
+	if output := render(remote+"#:api", false); !strings.Contains(output, "ServeV2") {
+		t.Fatalf("expected the mirror not to be fetched within its TTL, got:\n%s", output)
+	}
+
+	if output := render(remote+"#:api", true); !strings.Contains(output, "ServeV3") {
+		t.Fatalf("expected refresh to fetch the new commit, got:\n%s", output)
+	}
+
+	// Refs the mirror doesn't know about yet trigger a fetch
+	runTestGit(t, workDir, "tag", "v3")
+	runTestGit(t, workDir, "push", "-q", strings.TrimPrefix(remote, "file://"), "v3")
+
+	if output := render(remote+"#v3:api", false); !strings.Contains(output, "ServeV3") {
+		t.Fatalf("expected an unknown ref to fetch the mirror, got:\n%s", output)
+	}
+}
+
+func TestRenderGitRepoCacheConcurrent(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			output, err := RenderGitRepo(remote+"#:api", &RemoteRepoOptions{
+				Cache:    true,
+				CacheDir: cacheDir,
+			}, &RenderDirectoryOptions{FileOptions: &RenderFileOptions{}})
+			if err == nil && !strings.Contains(output, "ServeV2") {
+				err = fmt.Errorf("unexpected output:\n%s", output)
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("expected a single mirror left in the cache, got %v", entries)
+	}
+}
+
+func TestTreeFS(t *testing.T) {
+	_, workDir := newTestRemote(t)
+
+	repo, err := git.NewRepo(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := repo.TreeFS("v1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fstest.TestFS(fsys, "api/server.go", "docs/README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := fs.ReadFile(fsys, "api/server.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != "package api\n\nfunc Serve() {}\n" {
+		t.Errorf("expected server.go as of v1, got %q", text)
+	}
+}
+
+func TestRenderGitRepoContextSpec(t *testing.T) {
+	remote, _ := newTestRemote(t)
+
+	spec, err := ctxspec.ParseContextSpec("api/server.go Listen\napi/servr.go\ndocs/*.txt\n../outside.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mirrors are walked just like checkouts, so they warn about the same things
+	var warnings []string
+	output, err := RenderGitRepo(remote, &RemoteRepoOptions{
+		Cache:    true,
+		CacheDir: filepath.Join(t.TempDir(), "cache"),
+		CacheTTL: time.Hour,
+	}, &RenderDirectoryOptions{
+		ContextSpec: spec,
+		FileOptions: &RenderFileOptions{
+			Outline:        true,
+			OutputMarkdown: true,
+			OnWarning: func(w Warning) {
+				warnings = append(warnings, w.String())
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "func ServeV2() {}") || strings.Contains(output, "# Docs") {
+		t.Errorf("expected only server.go to be rendered, got:\n%s", output)
+	}
+
+	expected := []string{
+		`../outside.go: no such file in the context spec`,
+		`api/server.go: no symbol "Listen" to expand`,
+		`api/servr.go: no such file in the context spec. Did you mean api/server.go?`,
+		`docs/*.txt: no files match this pattern in the context spec`,
+	}
+	if fmt.Sprint(warnings) != fmt.Sprint(expected) {
+		t.Errorf("got warnings %q, expected %q", warnings, expected)
+	}
+}
@@ -0,0 +1,123 @@
+package llmcat
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/everestmz/llmcat/treesym"
+)
+
+// maxSuggestions is how many close matches are offered for something that
+// couldn't be found
+const maxSuggestions = 3
+
+// editDistance is the Levenshtein distance between a and b, ignoring case
+func editDistance(a, b string) int {
+	ra, rb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+
+	return prev[len(rb)]
+}
+
+// suggest returns the candidates closest to name, best first. Each candidate
+// is compared by all of its keys, like a full path and its base name, and only
+// close enough matches are returned
+func suggest(name string, candidates []string, keys func(candidate string) []string) []string {
+	type scored struct {
+		candidate string
+		distance  int
+		// edits is the true edit distance, which ranks parts of names below
+		// typos that are just as close
+		edits int
+	}
+
+	var matches []scored
+	for _, candidate := range candidates {
+		var best *scored
+		for _, key := range keys(candidate) {
+			edits := editDistance(name, key)
+			distance := edits
+			if len(name) >= 3 && strings.Contains(strings.ToLower(key), strings.ToLower(name)) {
+				// Part of a name is likely what was meant, like Render for RenderFile
+				distance = min(distance, 1)
+			}
+
+			if best == nil || distance < best.distance || (distance == best.distance && edits < best.edits) {
+				best = &scored{candidate, distance, edits}
+			}
+		}
+
+		// Allow about one typo for every three characters
+		if best != nil && best.distance <= max(2, len(name)/3) {
+			matches = append(matches, *best)
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b scored) int {
+		if a.distance != b.distance {
+			return a.distance - b.distance
+		}
+
+		return a.edits - b.edits
+	})
+
+	var suggestions []string
+	for _, match := range matches[:min(len(matches), maxSuggestions)] {
+		suggestions = append(suggestions, match.candidate)
+	}
+
+	return suggestions
+}
+
+// didYouMean formats suggestions to be added to the end of a warning
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(". Did you mean %s?", strings.Join(suggestions, ", "))
+}
+
+// suggestSymbols finds the definitions in psf closest to symbol, by both their
+// name and qualified name, and suggests their qualified names
+func suggestSymbols(symbol string, psf *treesym.ProcessedSourceFile) []string {
+	var candidates []string
+	names := map[string]string{}
+	for _, def := range psf.Definitions {
+		if _, ok := names[def.QualifiedName]; !ok {
+			candidates = append(candidates, def.QualifiedName)
+			names[def.QualifiedName] = def.Name
+		}
+	}
+
+	return suggest(symbol, candidates, func(candidate string) []string {
+		return []string{candidate, names[candidate]}
+	})
+}
+
+// suggestFiles finds the paths closest to relPath, by both their full path
+// and their base name
+func suggestFiles(relPath string, paths []string) []string {
+	return suggest(relPath, paths, func(candidate string) []string {
+		return []string{candidate, filepath.Base(candidate)}
+	})
+}
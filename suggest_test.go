@@ -0,0 +1,74 @@
+package llmcat
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "abc", b: "", want: 3},
+		{a: "RenderFile", b: "renderfile", want: 0},
+		{a: "RendrFile", b: "RenderFile", want: 1},
+		{a: "kitten", b: "sitting", want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	symbols := []string{
+		"RenderFileOptions",
+		"RenderFileOptions.Plan",
+		"RenderFileOptions.warn",
+		"RenderFileOptions.SetDefaults",
+		"renderFile",
+		"RenderDirectory",
+	}
+
+	// Symbols are compared by their qualified name and their name
+	keys := func(candidate string) []string {
+		return []string{candidate, candidate[strings.LastIndex(candidate, ".")+1:]}
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{
+			// A typo ranks above names that merely contain it
+			name: "renderFil",
+			want: []string{"renderFile", "RenderFileOptions", "RenderFileOptions.Plan"},
+		},
+		{
+			name: "RendrFile",
+			want: []string{"renderFile"},
+		},
+		{
+			name: "Plan",
+			want: []string{"RenderFileOptions.Plan"},
+		},
+		{
+			name: "nothing",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := suggest(tt.name, symbols, keys)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("suggest(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
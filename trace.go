@@ -0,0 +1,277 @@
+package llmcat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/everestmz/llmcat/treesym"
+)
+
+// TraceLocation is a line in a file that a stack trace or error points to
+type TraceLocation struct {
+	Path string
+	// Line starts from 1
+	Line int
+}
+
+func (l TraceLocation) String() string {
+	return fmt.Sprintf("%s:%d", l.Path, l.Line)
+}
+
+var (
+	// File "app/models.py", line 12, in save
+	pythonFramePattern = regexp.MustCompile(`File "([^"]+)", line (\d+)`)
+	// at com.example.Server.handle(Server.java:42)
+	javaFramePattern = regexp.MustCompile(`at\s+([\w$.<>/]+)\(([^():\s]+):(\d+)\)`)
+	// Go panics (/src/app/main.go:12 +0x1d), test failures (main_test.go:12:)
+	// and compiler errors (main.go:12:5: undefined: x)
+	fileLinePattern = regexp.MustCompile(`(?:^|\s)([^\s:()"']+\.\w+):(\d+)\b`)
+)
+
+// ParseTrace finds the locations in a Go panic, Python traceback, Java stack
+// trace or file:line:col compiler output, in the order they first appear
+func ParseTrace(r io.Reader) ([]TraceLocation, error) {
+	var locations []TraceLocation
+	add := func(path, line string) {
+		lineNum, err := strconv.Atoi(line)
+		if err != nil || lineNum < 1 {
+			return
+		}
+
+		location := TraceLocation{Path: path, Line: lineNum}
+		if !slices.Contains(locations, location) {
+			locations = append(locations, location)
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := pythonFramePattern.FindStringSubmatch(line); match != nil {
+			add(match[1], match[2])
+		} else if match := javaFramePattern.FindStringSubmatch(line); match != nil {
+			add(javaSourcePath(match[1], match[2]), match[3])
+		} else if match := fileLinePattern.FindStringSubmatch(line); match != nil {
+			add(match[1], match[2])
+		}
+	}
+
+	return locations, scanner.Err()
+}
+
+// javaSourcePath guesses where a Java frame's source file is from its package,
+// like com/example/Server.java for com.example.Server.handle in Server.java
+func javaSourcePath(method, filename string) string {
+	parts := strings.Split(method, ".")
+	if len(parts) < 3 {
+		return filename
+	}
+
+	// Leave off the class and method
+	return path.Join(append(parts[:len(parts)-2], filename)...)
+}
+
+// RenderTrace renders the files in dirName that locations point to. The
+// definitions around each location are expanded and the lines themselves are
+// marked in the gutter, and the rest of each file is outlined. Locations
+// outside of dirName, like the standard library, are left out with a warning
+func RenderTrace(dirName string, locations []TraceLocation, options *RenderDirectoryOptions) (string, error) {
+	err := options.SetDefaults()
+	if err != nil {
+		return "", err
+	}
+
+	dirName, err = filepath.Abs(dirName)
+	if err != nil {
+		return "", err
+	}
+
+	// Only the directory filters apply, not any context spec
+	everything := *options
+	everything.ContextSpec = nil
+
+	var paths []string
+	_, err = walkDirectory(dirName, &everything, func(_, relPath string) error {
+		paths = append(paths, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var warnings []Warning
+	var files []string
+	lines := map[string][]int{}
+	for i, matches := range resolveTracePaths(dirName, locations, paths) {
+		location := locations[i]
+		if len(matches) == 0 {
+			w := Warning{Path: location.String(), Message: "doesn't match any file here, so it's left out"}
+			options.FileOptions.warn(w)
+			warnings = append(warnings, w)
+			continue
+		} else if len(matches) > 1 {
+			w := Warning{Path: location.String(), Message: fmt.Sprintf("could be any of %s, so it's left out", strings.Join(matches, ", "))}
+			options.FileOptions.warn(w)
+			warnings = append(warnings, w)
+			continue
+		}
+
+		relPath := matches[0]
+		if _, ok := lines[relPath]; !ok {
+			files = append(files, relPath)
+		}
+		lines[relPath] = append(lines[relPath], location.Line)
+	}
+
+	if len(files) == 0 {
+		return "", fmt.Errorf("none of the %d locations in the trace are files in %s", len(locations), dirName)
+	}
+
+	var rendered []string
+	for _, relPath := range files {
+		fullPath := filepath.Join(dirName, relPath)
+		text, err := os.ReadFile(fullPath)
+		if err != nil {
+			return "", err
+		}
+
+		plan := options.FileOptions.Plan()
+		plan.Mode = RenderOutline
+		plan.Page = 0
+		plan.StartLine = 1
+		plan.PageSize = 0
+
+		psf, _ := parseFile(relPath, string(text), options.FileOptions)
+		for _, line := range lines[relPath] {
+			plan.Marked = append(plan.Marked, line-1)
+			if psf != nil {
+				plan.Expand = append(plan.Expand, enclosingDefinitions(psf, line-1)...)
+			}
+		}
+
+		output, fileWarnings, err := renderFile(relPath, fullPath, string(text), plan)
+		if err != nil {
+			return "", fmt.Errorf("error rendering file %s: %w", relPath, err)
+		}
+		rendered = append(rendered, output)
+		warnings = append(warnings, fileWarnings...)
+	}
+
+	return joinRenderedFiles(rendered, warnings), nil
+}
+
+// resolveTracePaths finds the files among paths, which are relative to
+// dirName, that each location's path refers to. Traces are often from
+// somewhere else, like a CI machine or a Java package, so paths can match by
+// their ending. Absolute paths that only match by their file name are checked
+// against where the other locations say the repo was, so that files in the
+// standard library aren't mistaken for ones at the top of the repo
+func resolveTracePaths(dirName string, locations []TraceLocation, paths []string) [][]string {
+	matches := make([][]string, len(locations))
+
+	// Where the repo was on the machine the trace is from
+	var roots []string
+	for i, location := range locations {
+		matches[i] = resolveTracePath(dirName, location.Path, paths)
+		if len(matches[i]) != 1 || !filepath.IsAbs(location.Path) {
+			continue
+		}
+
+		tracePath := path.Clean(filepath.ToSlash(location.Path))
+		if root, ok := strings.CutSuffix(tracePath, "/"+matches[i][0]); ok && !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+
+	for i, location := range locations {
+		if len(matches[i]) > 0 || !filepath.IsAbs(location.Path) {
+			continue
+		}
+
+		dir, name := path.Split(path.Clean(filepath.ToSlash(location.Path)))
+		if len(roots) > 0 {
+			if slices.Contains(roots, path.Clean(dir)) && slices.Contains(paths, name) {
+				matches[i] = []string{name}
+			}
+			continue
+		}
+
+		// Without anywhere to compare it to, a file name has to be unique
+		var named []string
+		for _, p := range paths {
+			if path.Base(p) == name {
+				named = append(named, p)
+			}
+		}
+
+		if len(named) == 1 {
+			matches[i] = named
+		}
+	}
+
+	return matches
+}
+
+// resolveTracePath finds the files among paths, which are relative to dirName,
+// that a path from a trace refers to, by the longest ending of it that any of
+// them has. Absolute paths have to match by more than their file name
+func resolveTracePath(dirName, tracePath string, paths []string) []string {
+	absolute := filepath.IsAbs(tracePath)
+	if absolute {
+		if relPath, err := filepath.Rel(dirName, tracePath); err == nil && slices.Contains(paths, filepath.ToSlash(relPath)) {
+			return []string{filepath.ToSlash(relPath)}
+		}
+	}
+
+	tracePath = strings.TrimPrefix(path.Clean(filepath.ToSlash(tracePath)), "/")
+	if slices.Contains(paths, tracePath) {
+		return []string{tracePath}
+	}
+
+	// Try shorter and shorter endings of the path until one matches
+	parts := strings.Split(tracePath, "/")
+	shortest := len(parts) - 1
+	if absolute {
+		shortest = len(parts) - 2
+	}
+
+	for i := 0; i <= shortest; i++ {
+		suffix := strings.Join(parts[i:], "/")
+
+		var matches []string
+		for _, p := range paths {
+			if p == suffix || strings.HasSuffix(p, "/"+suffix) {
+				matches = append(matches, p)
+			}
+		}
+
+		if len(matches) > 0 {
+			return matches
+		}
+	}
+
+	return nil
+}
+
+// enclosingDefinitions are the qualified names of the definitions that row is
+// part of, so that expanding them all shows it
+func enclosingDefinitions(psf *treesym.ProcessedSourceFile, row int) []string {
+	var names []string
+	for _, def := range psf.Definitions {
+		if int(def.StartPoint.Row) <= row && row <= int(def.EndPoint.Row) {
+			names = append(names, def.QualifiedName)
+		}
+	}
+
+	return names
+}
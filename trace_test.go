@@ -0,0 +1,140 @@
+package llmcat
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseTrace(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+		want  []TraceLocation
+	}{
+		{
+			name: "go panic",
+			trace: `panic: runtime error: index out of range [3] with length 3
+
+goroutine 1 [running]:
+main.handle(...)
+	/home/runner/work/app/app/server/handle.go:42 +0x1d
+main.main()
+	/home/runner/work/app/app/main.go:12 +0x5e
+exit status 2`,
+			want: []TraceLocation{
+				{Path: "/home/runner/work/app/app/server/handle.go", Line: 42},
+				{Path: "/home/runner/work/app/app/main.go", Line: 12},
+			},
+		},
+		{
+			name: "python traceback",
+			trace: `Traceback (most recent call last):
+  File "app/main.py", line 8, in <module>
+    run()
+  File "/srv/app/models.py", line 21, in run
+    raise ValueError("nope")
+ValueError: nope`,
+			want: []TraceLocation{
+				{Path: "app/main.py", Line: 8},
+				{Path: "/srv/app/models.py", Line: 21},
+			},
+		},
+		{
+			name: "java stack trace",
+			trace: `Exception in thread "main" java.lang.IllegalStateException: nope
+	at com.example.Server$Handler.handle(Server.java:42)
+	at com.example.Main.main(Main.java:7)
+	at java.base/java.lang.Thread.run(Unknown Source)`,
+			want: []TraceLocation{
+				{Path: "com/example/Server.java", Line: 42},
+				{Path: "com/example/Main.java", Line: 7},
+			},
+		},
+		{
+			name: "compiler errors",
+			trace: `# example.com/app
+./main.go:12:5: undefined: x
+server/handle.go:3:2: "fmt" imported and not used
+./main.go:12:9: too many errors`,
+			want: []TraceLocation{
+				{Path: "./main.go", Line: 12},
+				{Path: "server/handle.go", Line: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTrace(strings.NewReader(tt.trace))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderTrace(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "app")
+	text := "package server\n\nfunc Handle() {\n\ta := 1\n\tpanic(a)\n}\n\nfunc Other() {\n\treturn\n}\n"
+	writeTestFile(t, filepath.Join(dir, "server", "handle.go"), text)
+	writeTestFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n\tpanic(1)\n}\n")
+	writeTestFile(t, filepath.Join(dir, "a", "util.go"), "package a\n")
+	writeTestFile(t, filepath.Join(dir, "b", "util.go"), "package b\n")
+
+	output, err := RenderTrace(dir, []TraceLocation{
+		{Path: "/home/runner/work/app/app/server/handle.go", Line: 5},
+		{Path: "/usr/local/go/src/runtime/main.go", Line: 2},
+		{Path: "/home/runner/work/app/app/main.go", Line: 4},
+		{Path: "util.go", Line: 1},
+	}, &RenderDirectoryOptions{
+		FileOptions: &RenderFileOptions{
+			OutputMarkdown:  true,
+			ShowLineNumbers: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		"```server/handle.go",
+		"3  | func Handle() {\n4  | \ta := 1\n5  > \tpanic(a)\n6  | }",
+		"lines omitted: function Other",
+		"```main.go\n1 | package main\n2 | \n3 | func main() {\n4 > \tpanic(1)\n5 | }",
+		"- util.go:1: could be any of a/util.go, b/util.go, so it's left out",
+		"- /usr/local/go/src/runtime/main.go:2: doesn't match any file here, so it's left out",
+	} {
+		if !strings.Contains(output, s) {
+			t.Errorf("expected output to contain %q, got:\n%s", s, output)
+		}
+	}
+
+	if strings.Contains(output, "2 > ") {
+		t.Errorf("expected the standard library's main.go not to match, got:\n%s", output)
+	}
+
+	// A checkout in a directory with another name, with no other absolute
+	// paths to say where it was, still matches a file name that's unique
+	output, err = RenderTrace(dir, []TraceLocation{
+		{Path: "server/handle.go", Line: 5},
+		{Path: "/home/ci/checkout/main.go", Line: 4},
+	}, &RenderDirectoryOptions{FileOptions: &RenderFileOptions{OutputMarkdown: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "```main.go") || strings.Contains(output, "Warnings") {
+		t.Errorf("expected main.go to be matched by its file name, got:\n%s", output)
+	}
+
+	_, err = RenderTrace(dir, []TraceLocation{{Path: "/elsewhere/lib.go", Line: 1}}, &RenderDirectoryOptions{FileOptions: &RenderFileOptions{}})
+	if err == nil {
+		t.Errorf("expected a trace with no files in the directory to be an error")
+	}
+}
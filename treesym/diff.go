@@ -0,0 +1,100 @@
+package treesym
+
+type SymbolChangeType string
+
+const (
+	SymbolAdded   SymbolChangeType = "added"
+	SymbolRemoved SymbolChangeType = "removed"
+	SymbolChanged SymbolChangeType = "changed"
+)
+
+type SymbolChange struct {
+	Type SymbolChangeType
+	Name string
+	Kind string
+	// Before is nil for added symbols, After is nil for removed ones
+	Before *Node
+	After  *Node
+}
+
+type symbolKey struct {
+	// The qualified name without any overload index, which would shift as
+	// overloads are added and removed
+	name string
+	kind string
+	// Which occurrence of name & kind this is, so duplicates (like overloads)
+	// are compared in order
+	index int
+}
+
+func definitionsByKey(psf *ProcessedSourceFile) ([]symbolKey, map[symbolKey]*Node) {
+	var keys []symbolKey
+	nodes := map[symbolKey]*Node{}
+	if psf == nil {
+		return keys, nodes
+	}
+
+	counts := map[symbolKey]int{}
+	for _, def := range psf.Definitions {
+		base := symbolKey{name: trimOverloadIndex(def.QualifiedName), kind: def.Kind}
+		key := base
+		key.index = counts[base]
+		counts[base]++
+
+		keys = append(keys, key)
+		nodes[key] = def
+	}
+
+	return keys, nodes
+}
+
+// DiffSymbols compares the definitions in two versions of a file by name and
+// kind, and returns the ones that were added, removed, or whose text changed.
+// Either version may be nil, for files that were added or deleted. Added and
+// changed symbols come first in the order they appear in after, followed by
+// removed symbols in the order they appeared in before
+func DiffSymbols(before, after *ProcessedSourceFile) []*SymbolChange {
+	beforeKeys, beforeNodes := definitionsByKey(before)
+	afterKeys, afterNodes := definitionsByKey(after)
+
+	var changes []*SymbolChange
+
+	for _, key := range afterKeys {
+		afterNode := afterNodes[key]
+		beforeNode, ok := beforeNodes[key]
+		if !ok {
+			changes = append(changes, &SymbolChange{
+				Type:  SymbolAdded,
+				Name:  key.name,
+				Kind:  key.kind,
+				After: afterNode,
+			})
+			continue
+		}
+
+		if beforeNode.FullText != afterNode.FullText {
+			changes = append(changes, &SymbolChange{
+				Type:   SymbolChanged,
+				Name:   key.name,
+				Kind:   key.kind,
+				Before: beforeNode,
+				After:  afterNode,
+			})
+		}
+	}
+
+	for _, key := range beforeKeys {
+		if _, ok := afterNodes[key]; ok {
+			continue
+		}
+
+		changes = append(changes, &SymbolChange{
+			Type:   SymbolRemoved,
+			Name:   key.name,
+			Kind:   key.kind,
+			Before: beforeNodes[key],
+		})
+	}
+
+	return changes
+}
@@ -0,0 +1,231 @@
+package language
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// aliases has every language we know of, along with the other names it goes by
+// in modelines, shebangs and user config
+var aliases = map[Language][]string{
+	Python:     {"py", "python2", "python3", "starlark", "bazel"},
+	Javascript: {"js", "node", "nodejs"},
+	Typescript: {"ts", "deno"},
+	Tsx:        {"jsx"},
+	Go:         {"golang"},
+	Rust:       {"rs"},
+	Cpp:        {"cpp"},
+	C:          {},
+	Ruby:       {"rb"},
+	Java:       {},
+	Php:        {},
+	Kotlin:     {"kt"},
+	Swift:      {},
+	Scala:      {},
+	Lua:        {},
+	Bash:       {"sh", "shell", "zsh", "dash", "ksh"},
+	Yaml:       {"yml"},
+	Json:       {},
+	Toml:       {},
+	Markdown:   {"md"},
+	Dockerfile: {"docker"},
+	Make:       {"makefile"},
+}
+
+// filenames are files that are recognized by their whole name
+var filenames = map[string]Language{
+	"Dockerfile":      Dockerfile,
+	"Containerfile":   Dockerfile,
+	"Makefile":        Make,
+	"makefile":        Make,
+	"GNUmakefile":     Make,
+	"Rakefile":        Ruby,
+	"Gemfile":         Ruby,
+	"Guardfile":       Ruby,
+	"Vagrantfile":     Ruby,
+	"Podfile":         Ruby,
+	"BUILD":           Python,
+	"BUILD.bazel":     Python,
+	"WORKSPACE":       Python,
+	"WORKSPACE.bazel": Python,
+	"MODULE.bazel":    Python,
+	"Tiltfile":        Python,
+	"SConstruct":      Python,
+	"SConscript":      Python,
+	".bashrc":         Bash,
+	".bash_profile":   Bash,
+	".profile":        Bash,
+	".zshrc":          Bash,
+}
+
+// Parse returns the language with the given name or alias, like "python3" or "sh"
+func Parse(name string) (Language, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+
+	for lang, names := range aliases {
+		if slices.Contains(names, normalized) {
+			return lang, nil
+		}
+	}
+
+	lang := Language(normalized)
+	if _, ok := aliases[lang]; !ok {
+		return "", fmt.Errorf("unknown language %q", name)
+	}
+
+	return lang, nil
+}
+
+// Detector works out the language of a file
+type Detector struct {
+	// Overrides maps extensions (with a leading dot) and file names to
+	// languages, and takes priority over everything else
+	Overrides map[string]Language
+}
+
+// NewDetector returns a detector using mapping as its overrides. Keys starting
+// with a dot are extensions, and anything else is a whole file name. Values are
+// language names, as accepted by Parse
+func NewDetector(mapping map[string]string) (*Detector, error) {
+	d := &Detector{
+		Overrides: map[string]Language{},
+	}
+
+	for key, name := range mapping {
+		lang, err := Parse(name)
+		if err != nil {
+			return nil, fmt.Errorf("mapping %s: %w", key, err)
+		}
+
+		if strings.HasPrefix(key, ".") {
+			key = strings.ToLower(key)
+		}
+		d.Overrides[key] = lang
+	}
+
+	return d, nil
+}
+
+// Detect returns the language of the file at path, using the default detector
+func Detect(path, text string) (Language, error) {
+	return (&Detector{}).Detect(path, text)
+}
+
+// Detect returns the language of the file at path, whose contents are text.
+// In order, it checks the overrides, editor modelines, well known file names,
+// the extension and the shebang line
+func (d *Detector) Detect(path, text string) (Language, error) {
+	base := filepath.Base(path)
+	ext := strings.ToLower(filepath.Ext(base))
+
+	if lang, ok := d.Overrides[base]; ok {
+		return lang, nil
+	}
+
+	if lang, ok := d.Overrides[ext]; ok && ext != "" {
+		return lang, nil
+	}
+
+	if lang, ok := detectModeline(text); ok {
+		return lang, nil
+	}
+
+	if lang, ok := filenames[base]; ok {
+		return lang, nil
+	}
+
+	// Dockerfile.dev, prod.dockerfile and friends
+	if strings.HasPrefix(base, "Dockerfile.") || ext == ".dockerfile" {
+		return Dockerfile, nil
+	}
+
+	if ext == ".mk" {
+		return Make, nil
+	}
+
+	if ext == ".bzl" || ext == ".star" {
+		return Python, nil
+	}
+
+	if lang, err := GetLanguage(ext); err == nil {
+		return lang, nil
+	}
+
+	if lang, ok := detectShebang(text); ok {
+		return lang, nil
+	}
+
+	return "", ErrUnsupportedExtension
+}
+
+// detectShebang reads the interpreter from a line like #!/usr/bin/env python3
+func detectShebang(text string) (Language, bool) {
+	if !strings.HasPrefix(text, "#!") {
+		return "", false
+	}
+
+	line, _, _ := strings.Cut(text[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		// Skip past any flags to env, like -S
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = field
+				break
+			}
+		}
+	}
+
+	// Versioned interpreters, like python3.12
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	if interpreter == "" {
+		return "", false
+	}
+
+	lang, err := Parse(interpreter)
+	return lang, err == nil
+}
+
+var (
+	// vim: set ft=python: and vim: filetype=python, also with vi: and ex:
+	vimModeline = regexp.MustCompile(`(?:^|\s)(?:vim?|ex):.*?\b(?:ft|filetype|syntax)=([\w+-]+)`)
+	// -*- mode: python -*- and -*- python -*-
+	emacsModeline = regexp.MustCompile(`-\*-\s*(?:.*?\bmode:\s*)?([\w+-]+)\s*(?:;.*)?-\*-`)
+)
+
+// modelineLines is how many lines at the start and end of a file are checked
+// for modelines, which is the same as vim's default
+const modelineLines = 5
+
+func detectModeline(text string) (Language, bool) {
+	lines := strings.Split(text, "\n")
+
+	candidates := lines
+	if len(lines) > modelineLines*2 {
+		candidates = append(lines[:modelineLines:modelineLines], lines[len(lines)-modelineLines:]...)
+	}
+
+	for _, line := range candidates {
+		for _, modeline := range []*regexp.Regexp{vimModeline, emacsModeline} {
+			match := modeline.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+
+			if lang, err := Parse(match[1]); err == nil {
+				return lang, true
+			}
+		}
+	}
+
+	return "", false
+}
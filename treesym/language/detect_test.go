@@ -0,0 +1,58 @@
+package language
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	detector, err := NewDetector(map[string]string{
+		".tpl":     "go",
+		"Justfile": "sh",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		text string
+		want Language
+	}{
+		{path: "main.go", want: Go},
+		{path: "Main.PY", want: Python},
+		{path: "bin/deploy", text: "#!/usr/bin/env python3\nprint('hi')\n", want: Python},
+		{path: "bin/run", text: "#!/usr/bin/env -S node --no-warnings\n", want: Javascript},
+		{path: "scripts/build", text: "#!/bin/bash -e\n", want: Bash},
+		{path: "Dockerfile", want: Dockerfile},
+		{path: "docker/Dockerfile.dev", want: Dockerfile},
+		{path: "Makefile", want: Make},
+		{path: "Rakefile", want: Ruby},
+		{path: "pkg/BUILD", want: Python},
+		{path: "notes.txt", text: "def f():\n    pass\n# vim: set ft=python:\n", want: Python},
+		{path: "config", text: "# -*- mode: ruby; coding: utf-8 -*-\n", want: Ruby},
+		{path: "handler.js", text: "// vim: ft=typescript\n", want: Typescript},
+		{path: "templates/main.tpl", want: Go},
+		{path: "Justfile", want: Bash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := detector.Detect(tt.path, tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("detected %s, expected %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{"notes.txt", "bin/tool"} {
+		if lang, err := detector.Detect(path, "just some text\n"); err != ErrUnsupportedExtension {
+			t.Errorf("expected %s not to be detected, got %s (%v)", path, lang, err)
+		}
+	}
+
+	if _, err := NewDetector(map[string]string{".tpl": "cobol"}); err == nil {
+		t.Errorf("expected an unknown language to be rejected")
+	}
+}
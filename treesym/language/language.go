@@ -8,6 +8,16 @@ type Language string
 
 var ErrUnsupportedExtension = errors.New("unsupported file extension")
 
+// ErrUnsupportedLanguage is returned for languages that can be detected, but
+// that we can't parse
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// IsUnsupported returns true if err means we don't know how to parse a file,
+// rather than that something went wrong parsing it
+func IsUnsupported(err error) bool {
+	return errors.Is(err, ErrUnsupportedExtension) || errors.Is(err, ErrUnsupportedLanguage)
+}
+
 const (
 	Python     Language = "python"
 	Javascript Language = "javascript"
@@ -19,17 +29,37 @@ const (
 	C          Language = "c"
 	Ruby       Language = "ruby"
 	Java       Language = "java"
+	Php        Language = "php"
+	Kotlin     Language = "kotlin"
+	Swift      Language = "swift"
+	Scala      Language = "scala"
+	Lua        Language = "lua"
+	Bash       Language = "bash"
+	Yaml       Language = "yaml"
+	Json       Language = "json"
+	Toml       Language = "toml"
+	Markdown   Language = "markdown"
+	Dockerfile Language = "dockerfile"
+	Make       Language = "make"
 )
 
+// IsStructured returns true for data and markup languages, which are outlined
+// by their structure rather than by tags queries
+func IsStructured(lang Language) bool {
+	switch lang {
+	case Yaml, Json, Toml, Markdown:
+		return true
+	default:
+		return false
+	}
+}
+
 func GetLanguage(extension string) (Language, error) {
 	switch extension {
 	case ".py":
 		return Python, nil
-	case ".js":
-		fallthrough
-		// NOTE: we're doing this because the javascript tags are being weird, 
-		// maybe because the JS tree sitter grammar is out of date, or the tags just don't work
-		// return Javascript, nil
+	case ".js", ".mjs", ".cjs":
+		return Javascript, nil
 	case ".ts":
 		return Typescript, nil
 	case ".jsx", ".tsx":
@@ -46,6 +76,26 @@ func GetLanguage(extension string) (Language, error) {
 		return Java, nil
 	case ".c":
 		return C, nil
+	case ".php":
+		return Php, nil
+	case ".kt", ".kts":
+		return Kotlin, nil
+	case ".swift":
+		return Swift, nil
+	case ".scala", ".sc":
+		return Scala, nil
+	case ".lua":
+		return Lua, nil
+	case ".sh", ".bash":
+		return Bash, nil
+	case ".yaml", ".yml":
+		return Yaml, nil
+	case ".json":
+		return Json, nil
+	case ".toml":
+		return Toml, nil
+	case ".md", ".markdown":
+		return Markdown, nil
 	default:
 		return "", ErrUnsupportedExtension
 	}
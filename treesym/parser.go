@@ -2,33 +2,31 @@ package treesym
 
 import (
 	"fmt"
-	"path/filepath"
-	"strings"
 
 	"github.com/everestmz/llmcat/treesym/language"
 	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/bash"
 	"github.com/smacker/go-tree-sitter/c"
 	"github.com/smacker/go-tree-sitter/cpp"
 	"github.com/smacker/go-tree-sitter/golang"
 	"github.com/smacker/go-tree-sitter/java"
 	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/kotlin"
+	"github.com/smacker/go-tree-sitter/lua"
+	"github.com/smacker/go-tree-sitter/markdown/tree-sitter-markdown"
+	"github.com/smacker/go-tree-sitter/php"
 	"github.com/smacker/go-tree-sitter/python"
 	"github.com/smacker/go-tree-sitter/ruby"
 	"github.com/smacker/go-tree-sitter/rust"
+	"github.com/smacker/go-tree-sitter/scala"
+	"github.com/smacker/go-tree-sitter/swift"
+	"github.com/smacker/go-tree-sitter/toml"
 	"github.com/smacker/go-tree-sitter/typescript/tsx"
 	"github.com/smacker/go-tree-sitter/typescript/typescript"
+	"github.com/smacker/go-tree-sitter/yaml"
 )
 
-func GetTreeSitterLanguage(path string) (*sitter.Language, error) {
-	// Get the file extension
-	ext := strings.ToLower(filepath.Ext(path))
-
-	lang, err := language.GetLanguage(ext)
-	if err != nil {
-		return nil, err
-	}
-
-	// Set the appropriate language based on file extension
+func GetTreeSitterLanguage(lang language.Language) (*sitter.Language, error) {
 	switch lang {
 	case language.Python:
 		return python.GetLanguage(), nil
@@ -50,17 +48,37 @@ func GetTreeSitterLanguage(path string) (*sitter.Language, error) {
 		return java.GetLanguage(), nil
 	case language.C:
 		return c.GetLanguage(), nil
+	case language.Php:
+		return php.GetLanguage(), nil
+	case language.Kotlin:
+		return kotlin.GetLanguage(), nil
+	case language.Swift:
+		return swift.GetLanguage(), nil
+	case language.Scala:
+		return scala.GetLanguage(), nil
+	case language.Lua:
+		return lua.GetLanguage(), nil
+	case language.Bash:
+		return bash.GetLanguage(), nil
+	case language.Yaml, language.Json:
+		// JSON is a subset of YAML, so the YAML grammar parses it as flow mappings
+		return yaml.GetLanguage(), nil
+	case language.Toml:
+		return toml.GetLanguage(), nil
+	case language.Markdown:
+		// Only the block grammar, since outlines don't need inline markup
+		return tree_sitter_markdown.GetLanguage(), nil
 	default:
-		return nil, fmt.Errorf("unsupported language: %s", lang)
+		return nil, fmt.Errorf("%w: %s", language.ErrUnsupportedLanguage, lang)
 	}
 }
 
-// GetParser returns a tree-sitter parser based on the file extension
-func GetParser(path string) (*sitter.Parser, error) {
+// GetParser returns a tree-sitter parser for lang
+func GetParser(lang language.Language) (*sitter.Parser, error) {
 	// Create a new parser
 	parser := sitter.NewParser()
 
-	l, err := GetTreeSitterLanguage(path)
+	l, err := GetTreeSitterLanguage(lang)
 	if err != nil {
 		return nil, err
 	}
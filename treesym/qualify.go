@@ -0,0 +1,142 @@
+package treesym
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// qualifyDefinitions sets the QualifiedName of every definition in a code
+// file. Definitions are qualified by the types and classes they're declared
+// in, and definitions that still share a name, like Java overloads, are told
+// apart by a 1-based index in the order they appear
+func qualifyDefinitions(defs []*Node, nodes map[*Node]*sitter.Node, text []byte) {
+	type byteRange struct{ start, end uint32 }
+	containers := map[byteRange]*Node{}
+	for _, def := range defs {
+		node := nodes[def]
+		key := byteRange{node.StartByte(), node.EndByte()}
+		if _, ok := containers[key]; !ok {
+			containers[key] = def
+		}
+	}
+
+	// The definitions with each qualified name, in the order they appear
+	overloads := map[string][]byteRange{}
+	for _, def := range defs {
+		node := nodes[def]
+		own := byteRange{node.StartByte(), node.EndByte()}
+
+		var scope []string
+		if receiver := receiverType(node, text); receiver != "" {
+			scope = append(scope, receiver)
+		}
+
+		for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+			parentRange := byteRange{parent.StartByte(), parent.EndByte()}
+			if parentRange == own {
+				// Like a block holding nothing but this definition
+				continue
+			}
+
+			if container, ok := containers[parentRange]; ok {
+				scope = append([]string{container.Name}, scope...)
+			} else if implType := implTarget(parent, text); implType != "" {
+				scope = append([]string{implType}, scope...)
+			}
+		}
+
+		def.QualifiedName = strings.Join(append(scope, def.Name), ".")
+		if !slices.Contains(overloads[def.QualifiedName], own) {
+			overloads[def.QualifiedName] = append(overloads[def.QualifiedName], own)
+		}
+	}
+
+	for _, def := range defs {
+		ranges := overloads[def.QualifiedName]
+		if len(ranges) < 2 {
+			continue
+		}
+
+		node := nodes[def]
+		index := slices.Index(ranges, byteRange{node.StartByte(), node.EndByte()})
+		def.QualifiedName = fmt.Sprintf("%s#%d", def.QualifiedName, index+1)
+	}
+}
+
+// receiverType is the type a Go method is declared on, without any pointer or
+// type parameters
+func receiverType(node *sitter.Node, text []byte) string {
+	if node.Type() != "method_declaration" {
+		return ""
+	}
+
+	receiver := node.ChildByFieldName("receiver")
+	if receiver == nil {
+		return ""
+	}
+
+	return firstTypeIdentifier(receiver, text)
+}
+
+// implTarget is the type that a Rust impl block adds methods to
+func implTarget(node *sitter.Node, text []byte) string {
+	if node.Type() != "impl_item" {
+		return ""
+	}
+
+	target := node.ChildByFieldName("type")
+	if target == nil {
+		return ""
+	}
+
+	if target.Type() == "type_identifier" {
+		return target.Content(text)
+	}
+
+	return firstTypeIdentifier(target, text)
+}
+
+func firstTypeIdentifier(node *sitter.Node, text []byte) string {
+	if node.Type() == "type_identifier" {
+		return node.Content(text)
+	}
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		if name := firstTypeIdentifier(node.NamedChild(i), text); name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// trimOverloadIndex removes the #N that tells apart definitions with the same
+// qualified name
+func trimOverloadIndex(name string) string {
+	i := strings.LastIndex(name, "#")
+	if i < 0 {
+		return name
+	}
+
+	if _, err := strconv.Atoi(name[i+1:]); err != nil {
+		return name
+	}
+
+	return name[:i]
+}
+
+// Matches reports whether selector picks out the definition. A bare name
+// matches every definition with that name, a qualified name like Type.Method
+// matches all of that type's overloads, and Type.Method#2 matches just the
+// second one
+func (n *Node) Matches(selector string) bool {
+	if selector == n.Name || selector == n.QualifiedName {
+		return true
+	}
+
+	return selector == trimOverloadIndex(n.QualifiedName)
+}
@@ -0,0 +1,185 @@
+package treesym
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/everestmz/llmcat/treesym/language"
+	"github.com/everestmz/llmcat/treesym/tags"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// extendsDirective at the top of a query file, like in Neovim, means it's
+// added to the embedded query rather than replacing it
+var extendsDirective = regexp.MustCompile(`^;+\s*extends\s*$`)
+
+// LoadQueries reads <language>.scm tags queries from dir, and uses them in place
+// of the embedded ones. Files starting with a "; extends" comment are appended
+// to the embedded query instead. Every query is validated before any are used
+func LoadQueries(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.scm"))
+	if err != nil {
+		return err
+	}
+
+	loaded := map[language.Language]string{}
+	for _, path := range paths {
+		lang, err := language.Parse(strings.TrimSuffix(filepath.Base(path), ".scm"))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		query := string(contents)
+
+		err = ValidateQuery(lang, query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if isExtension(query) {
+			embedded, err := tags.GetTagsQuery(lang)
+			if err != nil && !errors.Is(err, language.ErrUnsupportedLanguage) {
+				return err
+			}
+			query = embedded + "\n" + query
+		}
+
+		loaded[lang] = query
+	}
+
+	for lang, query := range loaded {
+		tags.SetTagsQuery(lang, query)
+	}
+
+	return nil
+}
+
+func isExtension(query string) bool {
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, ";") {
+			return false
+		}
+
+		if extendsDirective.MatchString(line) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSymbolCapture reports whether captureName is the @<type>.<kind> capture
+// for a whole symbol
+func isSymbolCapture(captureName string) bool {
+	return strings.HasPrefix(captureName, "definition.") || strings.HasPrefix(captureName, "reference.")
+}
+
+// ValidateQuery checks that query compiles for lang, and that every pattern in
+// it has the captures GetSymbols expects: a @name.<type>.<kind> capture for the
+// symbol's name, a @<type>.<kind> capture for the whole symbol, where type is
+// definition or reference, and optionally a @doc capture. Other captures, like
+// a @scope used by predicates, are allowed and ignored. Errors say which line
+// the offending pattern is on
+func ValidateQuery(lang language.Language, query string) error {
+	if language.IsStructured(lang) {
+		return fmt.Errorf("%s is outlined by its structure, not by a tags query", lang)
+	}
+
+	tsLang, err := GetTreeSitterLanguage(lang)
+	if err != nil {
+		return err
+	}
+
+	// Compile errors already say which line and column they're on
+	q, err := sitter.NewQuery([]byte(query), tsLang)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	patternLines := patternStartLines(query)
+
+	for pattern := uint32(0); pattern < q.PatternCount(); pattern++ {
+		var names, symbols []string
+		for id := uint32(0); id < q.CaptureCount(); id++ {
+			if q.CaptureQuantifierForId(pattern, id) == sitter.QuantifierZero {
+				continue
+			}
+
+			captureName := q.CaptureNameForId(id)
+			switch {
+			case captureName == "doc":
+			case strings.HasPrefix(captureName, "name."):
+				names = append(names, captureName)
+			case isSymbolCapture(captureName):
+				symbols = append(symbols, captureName)
+			}
+		}
+
+		if len(names) == 1 && len(symbols) == 1 {
+			continue
+		}
+
+		location := fmt.Sprintf("pattern %d", pattern+1)
+		if int(pattern) < len(patternLines) {
+			location += fmt.Sprintf(" at line %d", patternLines[pattern])
+		}
+
+		captures := append(names, symbols...)
+		return fmt.Errorf("%s has captures [%s], but needs exactly one @name.<definition|reference>.<kind> capture and one @<definition|reference>.<kind> capture", location, strings.Join(captures, ", "))
+	}
+
+	return nil
+}
+
+// patternStartLines returns the line each top level pattern in query starts
+// on, in the order tree-sitter numbers them
+func patternStartLines(query string) []int {
+	var lines []int
+
+	line := 1
+	depth := 0
+	inString := false
+	inComment := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\n':
+			line++
+			inComment = false
+		case inComment:
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == ';':
+			inComment = true
+		case c == '"':
+			inString = true
+		case c == '(' || c == '[':
+			if depth == 0 {
+				lines = append(lines, line)
+			}
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,226 @@
+package treesym
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/everestmz/llmcat/treesym/language"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// structureWalker turns the syntax tree of a data or markup file into
+// definitions. Unlike code, these nest: every key with a multi-line value is a
+// definition named by its dotted key path, and every Markdown section is a
+// definition named by its heading
+type structureWalker struct {
+	text        []byte
+	lines       []string
+	definitions []*Node
+}
+
+func getStructuredSymbols(lang language.Language, root *sitter.Node, file *SourceFile) *ProcessedSourceFile {
+	w := &structureWalker{
+		text:  []byte(file.Text),
+		lines: strings.Split(file.Text, "\n"),
+	}
+
+	switch lang {
+	case language.Yaml, language.Json:
+		w.walkYaml(root, nil, nil)
+	case language.Toml:
+		w.walkToml(root)
+	case language.Markdown:
+		w.walkMarkdown(root, nil)
+	}
+
+	return &ProcessedSourceFile{
+		SourceFile: *file,
+		Symbols: Symbols{
+			Definitions: w.definitions,
+		},
+	}
+}
+
+// lastRow is the last row that node has content on. Nodes that swallow their
+// trailing newline end at column 0 of the following row
+func lastRow(node *sitter.Node) uint32 {
+	end := node.EndPoint()
+	if end.Column == 0 && end.Row > node.StartPoint().Row {
+		return end.Row - 1
+	}
+
+	return end.Row
+}
+
+// addDefinition records a definition spanning the rows of node from its first
+// row through endRow, summarized by the rows up to summaryEndRow. The node is
+// returned even if it has nothing to collapse, so that it can be used as the
+// parent of nested definitions
+func (w *structureWalker) addDefinition(node *sitter.Node, parent *Node, name, kind string, depth int, summaryEndRow, endRow uint32) *Node {
+	startRow := node.StartPoint().Row
+
+	def := &Node{
+		Range:           node.Range(),
+		SummaryEndPoint: sitter.Point{Row: summaryEndRow, Column: uint32(len(w.lines[summaryEndRow]))},
+		Name:            name,
+		QualifiedName:   name,
+		Kind:            kind,
+		Depth:           depth,
+		Parent:          parent,
+	}
+	def.EndPoint = sitter.Point{Row: endRow, Column: uint32(len(w.lines[endRow]))}
+
+	def.Summary = strings.Join(w.lines[startRow:summaryEndRow+1], "\n")
+	def.FullText = strings.Join(w.lines[startRow:endRow+1], "\n")
+
+	if endRow > summaryEndRow {
+		w.definitions = append(w.definitions, def)
+	}
+
+	return def
+}
+
+func unquoteKey(key string) string {
+	key = strings.TrimSpace(key)
+	if unquoted, err := strconv.Unquote(key); err == nil {
+		return unquoted
+	}
+
+	return strings.Trim(key, `'"`)
+}
+
+func appendPath(path []string, key string) []string {
+	return append(append([]string{}, path...), key)
+}
+
+// walkYaml handles both YAML and JSON, since JSON parses as YAML flow mappings
+func (w *structureWalker) walkYaml(node *sitter.Node, parent *Node, path []string) {
+	switch node.Type() {
+	case "block_mapping_pair", "flow_pair":
+		key := node.ChildByFieldName("key")
+		if key == nil {
+			return
+		}
+
+		keyPath := appendPath(path, unquoteKey(key.Content(w.text)))
+		start := node.StartPoint().Row
+		def := w.addDefinition(node, parent, strings.Join(keyPath, "."), "key", len(keyPath), start, lastRow(node))
+
+		if value := node.ChildByFieldName("value"); value != nil {
+			w.walkYaml(value, def, keyPath)
+		}
+	case "block_sequence", "flow_sequence":
+		var index int
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			item := node.NamedChild(i)
+			if item.Type() == "comment" {
+				continue
+			}
+
+			itemPath := appendPath(path, strconv.Itoa(index))
+			start := item.StartPoint().Row
+			def := w.addDefinition(item, parent, strings.Join(itemPath, "."), "item", len(itemPath), start, lastRow(item))
+			w.walkYaml(item, def, itemPath)
+			index++
+		}
+	default:
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			w.walkYaml(node.NamedChild(i), parent, path)
+		}
+	}
+}
+
+func tomlKeyPath(key *sitter.Node, text []byte) []string {
+	var path []string
+	for _, part := range strings.Split(key.Content(text), ".") {
+		path = append(path, unquoteKey(part))
+	}
+
+	return path
+}
+
+// walkToml adds a definition for every table, and for every key whose value
+// spans multiple lines. TOML tables aren't nested in the syntax tree, so a
+// table's parent is the last table whose name prefixes it
+func (w *structureWalker) walkToml(root *sitter.Node) {
+	tables := map[string]*Node{}
+
+	addPairs := func(node *sitter.Node, parent *Node, path []string) {
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			pair := node.NamedChild(i)
+			if pair.Type() != "pair" || pair.NamedChildCount() == 0 {
+				continue
+			}
+
+			keyPath := append(append([]string{}, path...), tomlKeyPath(pair.NamedChild(0), w.text)...)
+			start := pair.StartPoint().Row
+			w.addDefinition(pair, parent, strings.Join(keyPath, "."), "key", len(keyPath), start, lastRow(pair))
+		}
+	}
+
+	addPairs(root, nil, nil)
+
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		table := root.NamedChild(i)
+		if table.Type() != "table" && table.Type() != "table_array_element" {
+			continue
+		}
+
+		if table.NamedChildCount() == 0 {
+			continue
+		}
+
+		path := tomlKeyPath(table.NamedChild(0), w.text)
+
+		var parent *Node
+		for j := len(path) - 1; j > 0 && parent == nil; j-- {
+			parent = tables[strings.Join(path[:j], ".")]
+		}
+
+		name := strings.Join(path, ".")
+		start := table.StartPoint().Row
+		def := w.addDefinition(table, parent, name, "table", len(path), start, lastRow(table))
+		tables[name] = def
+
+		addPairs(table, def, path)
+	}
+}
+
+// walkMarkdown adds a definition for every section, covering its heading and
+// the text up to its first subsection. Subsections are their own definitions,
+// so collapsing a section still leaves the headings beneath it visible
+func (w *structureWalker) walkMarkdown(node *sitter.Node, parent *Node) {
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		section := node.NamedChild(i)
+		if section.Type() != "section" {
+			continue
+		}
+
+		heading := section.NamedChild(0)
+		if heading == nil || (heading.Type() != "atx_heading" && heading.Type() != "setext_heading") {
+			w.walkMarkdown(section, parent)
+			continue
+		}
+
+		name := heading.Content(w.text)
+		if content := heading.ChildByFieldName("heading_content"); content != nil {
+			name = content.Content(w.text)
+		} else {
+			name, _, _ = strings.Cut(name, "\n")
+		}
+
+		headingEnd := lastRow(heading)
+		bodyEnd := lastRow(section)
+		for j := 1; j < int(section.NamedChildCount()); j++ {
+			child := section.NamedChild(j)
+			if child.Type() == "section" {
+				bodyEnd = child.StartPoint().Row - 1
+				break
+			}
+		}
+		bodyEnd = max(bodyEnd, headingEnd)
+
+		def := w.addDefinition(section, parent, strings.TrimSpace(name), "section", 0, headingEnd, bodyEnd)
+		w.walkMarkdown(section, def)
+	}
+}
@@ -0,0 +1,5 @@
+(function_definition
+  name: (word) @name.definition.function) @definition.function
+
+(command
+  name: (command_name) @name.reference.call) @reference.call
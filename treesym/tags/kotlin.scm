@@ -0,0 +1,11 @@
+(class_declaration
+  (type_identifier) @name.definition.class) @definition.class
+
+(object_declaration
+  (type_identifier) @name.definition.object) @definition.object
+
+(function_declaration
+  (simple_identifier) @name.definition.function) @definition.function
+
+(call_expression
+  (simple_identifier) @name.reference.call) @reference.call
@@ -0,0 +1,8 @@
+(function_statement
+  name: [
+    (identifier)
+    (function_name)
+  ] @name.definition.function) @definition.function
+
+(function_call
+  prefix: (identifier) @name.reference.call) @reference.call
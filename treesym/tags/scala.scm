@@ -0,0 +1,14 @@
+(class_definition
+  name: (identifier) @name.definition.class) @definition.class
+
+(object_definition
+  name: (identifier) @name.definition.object) @definition.object
+
+(trait_definition
+  name: (identifier) @name.definition.interface) @definition.interface
+
+(function_definition
+  name: (identifier) @name.definition.function) @definition.function
+
+(call_expression
+  function: (identifier) @name.reference.call) @reference.call
@@ -0,0 +1,14 @@
+(class_declaration
+  name: (type_identifier) @name.definition.class) @definition.class
+
+(protocol_declaration
+  name: (type_identifier) @name.definition.interface) @definition.interface
+
+(function_declaration
+  name: (simple_identifier) @name.definition.function) @definition.function
+
+(init_declaration
+  "init" @name.definition.method) @definition.method
+
+(call_expression
+  (simple_identifier) @name.reference.call) @reference.call
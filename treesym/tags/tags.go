@@ -34,6 +34,24 @@ var (
 
 	//go:embed javascript.scm
     JavascriptTags string
+
+	//go:embed php.scm
+	PhpTags string
+
+	//go:embed kotlin.scm
+	KotlinTags string
+
+	//go:embed swift.scm
+	SwiftTags string
+
+	//go:embed scala.scm
+	ScalaTags string
+
+	//go:embed lua.scm
+	LuaTags string
+
+	//go:embed bash.scm
+	BashTags string
 )
 
 var queries = map[language.Language]string{
@@ -47,12 +65,24 @@ var queries = map[language.Language]string{
 	language.Cpp: CppTags,
 	language.Ruby: RubyTags,
 	language.Java: JavaTags,
+	language.Php: PhpTags,
+	language.Kotlin: KotlinTags,
+	language.Swift: SwiftTags,
+	language.Scala: ScalaTags,
+	language.Lua: LuaTags,
+	language.Bash: BashTags,
 }
 
 func GetTagsQuery(lang language.Language) (string, error) {
 	query, ok := queries[lang]
 	if !ok {
-		return "", fmt.Errorf("unsupported language: %s", lang)
+		return "", fmt.Errorf("%w: no tags query for %s", language.ErrUnsupportedLanguage, lang)
 	}
 	return query, nil
 }
+
+// SetTagsQuery replaces the query used for lang, like when the user supplies
+// their own. It must already have been validated
+func SetTagsQuery(lang language.Language, query string) {
+	queries[lang] = query
+}
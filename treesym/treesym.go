@@ -3,7 +3,6 @@ package treesym
 import (
 	"context"
 	"fmt"
-	"path/filepath"
 	"strings"
 
 	"github.com/everestmz/llmcat/treesym/language"
@@ -14,16 +13,29 @@ import (
 type SourceFile struct {
 	Path string
 	Text string
+	// Language is detected from Path and Text if it's empty
+	Language language.Language
 }
 
 type Node struct {
 	sitter.Range
 	SummaryEndPoint sitter.Point
 	Name            string
-	Summary         string
-	FullText        string
-	Kind            string
-	Documentation   string
+	// QualifiedName tells apart definitions that share a name, like String
+	// methods on different types or overloads. In code, it's prefixed with the
+	// enclosing types, like Type.Method, and suffixed with #N if it's still not
+	// unique. In data files and Markdown, it's the same as Name
+	QualifiedName string
+	Summary       string
+	FullText      string
+	Kind          string
+	Documentation string
+	// Depth is how many keys deep a definition in a data file is, starting at
+	// 1 for top level keys. It's 0 for code and Markdown
+	Depth int
+	// Parent is the enclosing definition in data and Markdown files, which
+	// nest. It's always nil for code
+	Parent *Node
 }
 
 type Symbols struct {
@@ -32,10 +44,12 @@ type Symbols struct {
 }
 
 type OutlineChunk struct {
-	// Name only set if item is omitted, since otherwise chunk could be bigger than a single symbol
-	Name       string
-	Content    string
-	ShouldOmit bool
+	// Name, QualifiedName and Kind only set if item is omitted, since otherwise chunk could be bigger than a single symbol
+	Name          string
+	QualifiedName string
+	Kind          string
+	Content       string
+	ShouldOmit    bool
 	// 0-indexed, like tree-sitter rows are
 	StartRow int
 	EndRow   int
@@ -56,6 +70,9 @@ func (oc *OutlineChunk) AddLine(line string) {
 type ProcessedSourceFile struct {
 	SourceFile
 	Symbols
+	// Warnings are problems that make the symbols incomplete or unreliable,
+	// like syntax errors, but that didn't stop the file from being processed
+	Warnings []string
 }
 
 // GetOutline runs through all of the definitions in the outline, and returns
@@ -63,13 +80,34 @@ type ProcessedSourceFile struct {
 // be omitted for summarization, ShouldOmit is true, but the chunk is still
 // returned, so that omitted items can be easily expanded
 func (psf *ProcessedSourceFile) GetOutline() []*OutlineChunk {
+	return psf.GetOutlineFunc(nil)
+}
+
+// GetOutlineFunc is like GetOutline, but definitions for which expand returns
+// true aren't omitted. This matters when definitions nest: an expanded
+// definition can still contain omitted ones, while anything inside an omitted
+// definition is omitted along with it
+func (psf *ProcessedSourceFile) GetOutlineFunc(expand func(def *Node) bool) []*OutlineChunk {
 	lines := strings.Split(psf.Text, "\n")
 
 	var getLine = func(lineNum int) string {
 		return lines[lineNum]
 	}
 
+	// Some definitions share a body, like a named function expression and the
+	// property it's assigned to. Expanding either should show it
+	type bodyRange struct{ start, end uint32 }
+	expandedBodies := map[bodyRange]bool{}
+	if expand != nil {
+		for _, def := range psf.Definitions {
+			if def.FullText != def.Summary && expand(def) {
+				expandedBodies[bodyRange{def.SummaryEndPoint.Row, def.EndPoint.Row}] = true
+			}
+		}
+	}
+
 	var summaryChunks []*OutlineChunk
+	omittedUntil := -1
 
 	// In this first pass, we go through and create chunks only for omitted sections
 	// We're also extending summaries to full line chunks, which are easier to manage
@@ -79,15 +117,27 @@ func (psf *ProcessedSourceFile) GetOutline() []*OutlineChunk {
 			continue
 		}
 
+		if expandedBodies[bodyRange{def.SummaryEndPoint.Row, def.EndPoint.Row}] {
+			continue
+		}
+
 		// We start on the line after the summary finishes
 		startLine := int(def.SummaryEndPoint.Row) + 1
 		endLine := int(def.EndPoint.Row)
 
+		if startLine <= omittedUntil {
+			// Nested inside a definition we're already omitting
+			continue
+		}
+		omittedUntil = endLine
+
 		currentChunk := &OutlineChunk{
-			ShouldOmit: true,
-			StartRow:   startLine,
-			EndRow:     endLine,
-			Name:       def.Name,
+			ShouldOmit:    true,
+			StartRow:      startLine,
+			EndRow:        endLine,
+			Name:          def.Name,
+			QualifiedName: def.QualifiedName,
+			Kind:          def.Kind,
 		}
 
 		for currentLine := startLine; currentLine <= endLine; currentLine++ {
@@ -120,7 +170,7 @@ func (psf *ProcessedSourceFile) GetOutline() []*OutlineChunk {
 
 		// We're in a summary block
 		currentChunk.EndRow = currentLine - 1
-		if len(currentChunk.Content) > 0 {
+		if currentChunk.hasLines {
 			chunks = append(chunks, currentChunk)
 		}
 
@@ -140,16 +190,32 @@ func (psf *ProcessedSourceFile) GetOutline() []*OutlineChunk {
 		}
 	}
 
+	// Whatever comes after the last omitted section. If nothing could be
+	// omitted, there's no outline at all
+	if currentChunk.hasLines && len(chunks) > 0 {
+		currentChunk.EndRow = len(lines) - 1
+		chunks = append(chunks, currentChunk)
+	}
+
 	return chunks
 }
 
 func GetSymbols(ctx context.Context, file *SourceFile) (*ProcessedSourceFile, error) {
-	tsLang, err := GetTreeSitterLanguage(file.Path)
+	lang := file.Language
+	if lang == "" {
+		var err error
+		lang, err = language.Detect(file.Path, file.Text)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tsLang, err := GetTreeSitterLanguage(lang)
 	if err != nil {
 		return nil, err
 	}
 
-	parser, err := GetParser(file.Path)
+	parser, err := GetParser(lang)
 	if err != nil {
 		return nil, err
 	}
@@ -159,11 +225,15 @@ func GetSymbols(ctx context.Context, file *SourceFile) (*ProcessedSourceFile, er
 		return nil, fmt.Errorf("parsing code: %w", err)
 	}
 
-	ext := filepath.Ext(file.Path)
+	var warnings []string
+	if warning := syntaxErrorWarning(tree.RootNode()); warning != "" {
+		warnings = append(warnings, warning)
+	}
 
-	lang, err := language.GetLanguage(ext)
-	if err != nil {
-		return nil, err
+	if language.IsStructured(lang) {
+		psf := getStructuredSymbols(lang, tree.RootNode(), file)
+		psf.Warnings = warnings
+		return psf, nil
 	}
 
 	tagsQuery, err := tags.GetTagsQuery(lang)
@@ -183,8 +253,34 @@ func GetSymbols(ctx context.Context, file *SourceFile) (*ProcessedSourceFile, er
 	psf := &ProcessedSourceFile{
 		SourceFile: *file,
 		Symbols:    Symbols{},
+		Warnings:   warnings,
+	}
+
+	// The syntax nodes of definitions, to work out what they're declared in
+	definitionNodes := map[*Node]*sitter.Node{}
+	type definitionKey struct {
+		name       string
+		start, end uint32
+	}
+	definitionKeys := map[definitionKey]bool{}
+	addDefinition := func(node *Node, syntaxNode *sitter.Node) {
+		// Several patterns can match the same definition, like a Rust
+		// function that's also a method
+		key := definitionKey{node.Name, syntaxNode.StartByte(), syntaxNode.EndByte()}
+		if definitionKeys[key] {
+			return
+		}
+		definitionKeys[key] = true
+
+		psf.Symbols.Definitions = append(psf.Symbols.Definitions, node)
+		definitionNodes[node] = syntaxNode
 	}
 
+	// Matches we couldn't make sense of, by pattern, so we can warn once for each
+	var skippedPatterns []uint16
+	skippedMatches := map[uint16]int{}
+	skippedCaptures := map[uint16]string{}
+
 	for {
 		m, ok := qc.NextMatch()
 		if !ok {
@@ -192,6 +288,10 @@ func GetSymbols(ctx context.Context, file *SourceFile) (*ProcessedSourceFile, er
 		}
 
 		m = qc.FilterPredicates(m, []byte(file.Text))
+		if len(m.Captures) == 0 {
+			// A predicate like #not-eq? rejected the match
+			continue
+		}
 
 		var docs string
 		var nameCapture sitter.QueryCapture
@@ -205,19 +305,27 @@ func GetSymbols(ctx context.Context, file *SourceFile) (*ProcessedSourceFile, er
 				numNonDocCaptures++
 			} else if captureName == "doc" {
 				docs = cap.Node.Content([]byte(file.Text))
-			} else {
+			} else if isSymbolCapture(captureName) {
 				contentCapture = cap
 				numNonDocCaptures++
 			}
+			// Anything else, like a @scope, is only there for the query's own
+			// predicates
 		}
 
 		if numNonDocCaptures != 2 {
-			// XXX: not sure if this will always be 2. But for now, I think it is.
-			var captureNames []string
-			for _, cap := range m.Captures {
-				captureNames = append(captureNames, q.CaptureNameForId(cap.Index))
+			// Every pattern should capture a name and a symbol, but queries
+			// aren't always perfect. Skip the match rather than the whole file
+			if skippedMatches[m.PatternIndex] == 0 {
+				var captureNames []string
+				for _, cap := range m.Captures {
+					captureNames = append(captureNames, q.CaptureNameForId(cap.Index))
+				}
+				skippedPatterns = append(skippedPatterns, m.PatternIndex)
+				skippedCaptures[m.PatternIndex] = strings.Join(captureNames, ", ")
 			}
-			return nil, fmt.Errorf("Got unexpected number of captures (%d) id:%d pattern_index:%d captures: %s", len(m.Captures), m.ID, m.PatternIndex, strings.Join(captureNames, ","))
+			skippedMatches[m.PatternIndex]++
+			continue
 		}
 
 		captureName := q.CaptureNameForId(contentCapture.Index)
@@ -242,7 +350,7 @@ func GetSymbols(ctx context.Context, file *SourceFile) (*ProcessedSourceFile, er
 
 			switch captureType {
 			case "definition":
-				psf.Symbols.Definitions = append(psf.Symbols.Definitions, node)
+				addDefinition(node, contentCapture.Node)
 			case "reference":
 				psf.Symbols.References = append(psf.Symbols.References, node)
 			}
@@ -282,12 +390,69 @@ func GetSymbols(ctx context.Context, file *SourceFile) (*ProcessedSourceFile, er
 		node.Summary = file.Text[startByte:endByte]
 		node.SummaryEndPoint = curMaxNode.EndPoint()
 
-		psf.Symbols.Definitions = append(psf.Symbols.Definitions, node)
+		addDefinition(node, contentCapture.Node)
+	}
+
+	qualifyDefinitions(psf.Symbols.Definitions, definitionNodes, []byte(file.Text))
+
+	for _, pattern := range skippedPatterns {
+		psf.Warnings = append(psf.Warnings, fmt.Sprintf("skipped %d match(es) of tags query pattern %d, which captured [%s] rather than a name and a symbol, so the outline may be incomplete", skippedMatches[pattern], pattern+1, skippedCaptures[pattern]))
 	}
 
 	return psf, nil
 }
 
+// maxSyntaxErrorLines is how many lines with syntax errors are listed in a
+// warning before the rest are just counted
+const maxSyntaxErrorLines = 5
+
+// syntaxErrorWarning describes where tree-sitter couldn't parse the tree rooted
+// at root, or returns an empty string if it parsed cleanly
+func syntaxErrorWarning(root *sitter.Node) string {
+	if !root.HasError() {
+		return ""
+	}
+
+	var lines []int
+	var visit func(node *sitter.Node)
+	visit = func(node *sitter.Node) {
+		if node.IsError() || node.IsMissing() {
+			line := int(node.StartPoint().Row) + 1
+			if len(lines) == 0 || lines[len(lines)-1] != line {
+				lines = append(lines, line)
+			}
+			// Anything inside an error is part of the same error
+			return
+		}
+
+		if !node.HasError() {
+			return
+		}
+
+		for i := 0; i < int(node.ChildCount()); i++ {
+			visit(node.Child(i))
+		}
+	}
+	visit(root)
+
+	var listed []string
+	for _, line := range lines[:min(len(lines), maxSyntaxErrorLines)] {
+		listed = append(listed, fmt.Sprint(line))
+	}
+	if len(lines) > maxSyntaxErrorLines {
+		listed = append(listed, fmt.Sprintf("and %d more", len(lines)-maxSyntaxErrorLines))
+	}
+
+	switch len(lines) {
+	case 0:
+		return "syntax errors, so the outline may be unreliable"
+	case 1:
+		return fmt.Sprintf("syntax error on line %d, so the outline may be unreliable", lines[0])
+	default:
+		return fmt.Sprintf("syntax errors on lines %s, so the outline may be unreliable", strings.Join(listed, ", "))
+	}
+}
+
 func seekNewLine(text string, startIndex uint32, maxSeekLength uint32) uint32 {
 	for i := uint32(0); i < maxSeekLength; i++ {
 		index := startIndex + i
@@ -3,7 +3,13 @@ package treesym
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/everestmz/llmcat/treesym/language"
+	"github.com/everestmz/llmcat/treesym/tags"
 )
 
 const pythonSample = `
@@ -207,3 +213,773 @@ Text string
 		fmt.Println(chunk.Content)
 	}
 }
+
+func TestGetOutlineTrailingLines(t *testing.T) {
+	proc, err := GetSymbols(context.TODO(), &SourceFile{
+		Path: "main.go",
+		Text: "package main\n\nfunc A() {\n\tprintln(1)\n}\n\nvar Tail = 1\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outline := proc.GetOutline()
+	if len(outline) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(outline))
+	}
+
+	// The lines after the last omitted body are their own chunk
+	last := outline[len(outline)-1]
+	if last.ShouldOmit || last.StartRow != 5 || last.EndRow != 7 || !strings.Contains(last.Content, "var Tail = 1") {
+		t.Errorf("expected a chunk for rows 5-7 with the trailing lines, got rows %d-%d:\n%s", last.StartRow, last.EndRow, last.Content)
+	}
+
+	// With nothing to omit, there's no outline at all
+	proc, err = GetSymbols(context.TODO(), &SourceFile{
+		Path: "main.go",
+		Text: "package main\n\nvar Tail = 1\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if outline := proc.GetOutline(); len(outline) != 0 {
+		t.Errorf("expected no outline, got %d chunks", len(outline))
+	}
+}
+
+func TestDiffSymbols(t *testing.T) {
+	before, err := GetSymbols(context.TODO(), &SourceFile{
+		Path: "diff.go",
+		Text: `package diff
+
+func Unchanged() {
+	return
+}
+
+func Changed() {
+	return
+}
+
+func Removed() {
+	return
+}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := GetSymbols(context.TODO(), &SourceFile{
+		Path: "diff.go",
+		Text: `package diff
+
+func Added() {
+	return
+}
+
+func Unchanged() {
+	return
+}
+
+func Changed() {
+	panic("changed")
+}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := DiffSymbols(before, after)
+
+	expected := []struct {
+		changeType SymbolChangeType
+		name       string
+	}{
+		{SymbolAdded, "Added"},
+		{SymbolChanged, "Changed"},
+		{SymbolRemoved, "Removed"},
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("got %d changes, expected %d", len(changes), len(expected))
+	}
+
+	for i, change := range changes {
+		if change.Type != expected[i].changeType || change.Name != expected[i].name {
+			t.Fatalf("change %d is %s %s, expected %s %s", i, change.Type, change.Name, expected[i].changeType, expected[i].name)
+		}
+	}
+
+	for _, change := range DiffSymbols(nil, after) {
+		if change.Type != SymbolAdded {
+			t.Fatalf("expected every symbol in a new file to be added, got %s %s", change.Type, change.Name)
+		}
+	}
+}
+
+func TestLanguageOutlines(t *testing.T) {
+	tests := []struct {
+		path    string
+		text    string
+		omitted []string
+	}{
+		{
+			path: "example.php",
+			text: `<?php
+
+namespace App;
+
+interface Greeter {
+    public function greet(string $name): string;
+}
+
+class Hello implements Greeter {
+    public function greet(string $name): string {
+        return "Hello " . $name;
+    }
+}
+
+function helper($x) {
+    return $x * 2;
+}
+`,
+			omitted: []string{"greet", "helper"},
+		},
+		{
+			path: "Example.kt",
+			text: `package app
+
+interface Greeter {
+    fun greet(name: String): String
+}
+
+class Hello : Greeter {
+    override fun greet(name: String): String {
+        return "Hello $name"
+    }
+}
+
+object Registry {
+    fun register() {
+        println("registered")
+    }
+}
+
+fun helper(x: Int): Int {
+    return x * 2
+}
+`,
+			omitted: []string{"greet", "register", "helper"},
+		},
+		{
+			path: "Example.swift",
+			text: `import Foundation
+
+protocol Greeter {
+    func greet(name: String) -> String
+}
+
+class Hello: Greeter {
+    init() {
+        print("init")
+    }
+
+    func greet(name: String) -> String {
+        return "Hello \(name)"
+    }
+}
+
+struct Point {
+    var x: Int
+}
+
+func helper(x: Int) -> Int {
+    return x * 2
+}
+`,
+			omitted: []string{"init", "greet", "helper"},
+		},
+		{
+			path: "Example.scala",
+			text: `package app
+
+trait Greeter {
+  def greet(name: String): String
+}
+
+class Hello extends Greeter {
+  def greet(name: String): String = {
+    "Hello " + name
+  }
+}
+
+object Main {
+  def main(args: Array[String]): Unit = {
+    println("hi")
+  }
+}
+`,
+			omitted: []string{"greet", "main"},
+		},
+		{
+			path: "example.lua",
+			text: `local M = {}
+
+function M.greet(name)
+  return "Hello " .. name
+end
+
+function M:method(x)
+  return x
+end
+
+local function helper(x)
+  return x * 2
+end
+
+function global_fn()
+  print("hi")
+end
+
+return M
+`,
+			omitted: []string{"M.greet", "M:method", "helper", "global_fn"},
+		},
+		{
+			path: "example.sh",
+			text: `#!/bin/bash
+set -e
+
+greet() {
+  echo "Hello $1"
+}
+
+function deploy {
+  echo "deploying"
+  greet world
+}
+
+deploy
+`,
+			omitted: []string{"greet", "deploy"},
+		},
+		{
+			path: "handler.cjs",
+			text: `const path = require("path");
+
+module.exports = function handler(req, res) {
+  res.send("ok");
+};
+
+module.exports.helper = function () {
+  return 1;
+};
+
+exports.other = (x) => {
+  return x * 2;
+};
+
+function Person(name) {
+  this.name = name;
+}
+
+Person.prototype.greet = function () {
+  return "Hello " + this.name;
+};
+`,
+			omitted: []string{"exports", "helper", "other", "Person", "greet"},
+		},
+		{
+			path: "api.js",
+			text: `const api = {
+  get(url) {
+    return fetch(url);
+  },
+  post: function (url, body) {
+    return fetch(url, { method: "POST", body });
+  },
+  put: async (url) => {
+    return fetch(url);
+  },
+};
+`,
+			omitted: []string{"get", "post", "put"},
+		},
+		{
+			path: "animal.mjs",
+			text: `export class Animal extends Base {
+  constructor(name) {
+    super();
+    this.name = name;
+  }
+
+  speak() {
+    console.log(this.name);
+  }
+}
+
+export default function main() {
+  new Animal("dog").speak();
+}
+`,
+			omitted: []string{"constructor", "speak", "main"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			proc, err := GetSymbols(context.TODO(), &SourceFile{
+				Path: tt.path,
+				Text: tt.text,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var omitted []string
+			for _, chunk := range proc.GetOutline() {
+				if chunk.ShouldOmit {
+					omitted = append(omitted, chunk.Name)
+				}
+			}
+
+			if fmt.Sprint(omitted) != fmt.Sprint(tt.omitted) {
+				t.Fatalf("omitted %v, expected %v", omitted, tt.omitted)
+			}
+		})
+	}
+}
+
+func TestQualifiedNames(t *testing.T) {
+	tests := []struct {
+		path      string
+		text      string
+		qualified []string
+	}{
+		{
+			path: "buffer.go",
+			text: `package buffer
+
+type Buffer struct{}
+
+func (b *Buffer) String() string {
+	return ""
+}
+
+type List[T any] struct{}
+
+func (l List[T]) String() string {
+	return ""
+}
+
+func String() string {
+	return ""
+}
+`,
+			qualified: []string{"Buffer", "Buffer.String", "List", "List.String", "String"},
+		},
+		{
+			path: "Greeter.java",
+			text: `class Greeter {
+    String greet(String name) {
+        return "Hello " + name;
+    }
+
+    String greet(String name, int times) {
+        return greet(name).repeat(times);
+    }
+}
+`,
+			qualified: []string{"Greeter", "Greeter.greet#1", "Greeter.greet#2"},
+		},
+		{
+			path: "animal.py",
+			text: `class Animal:
+    def speak(self):
+        return "..."
+
+class Dog(Animal):
+    def speak(self):
+        return "woof"
+`,
+			qualified: []string{"Animal", "Animal.speak", "Dog", "Dog.speak"},
+		},
+		{
+			path: "point.rs",
+			text: `struct Point {
+    x: i32,
+}
+
+impl Point {
+    fn new(x: i32) -> Point {
+        Point { x }
+    }
+}
+`,
+			qualified: []string{"Point", "Point.new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			proc, err := GetSymbols(context.TODO(), &SourceFile{
+				Path: tt.path,
+				Text: tt.text,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var qualified []string
+			for _, def := range proc.Definitions {
+				qualified = append(qualified, def.QualifiedName)
+			}
+
+			if fmt.Sprint(qualified) != fmt.Sprint(tt.qualified) {
+				t.Fatalf("qualified names are %v, expected %v", qualified, tt.qualified)
+			}
+		})
+	}
+}
+
+func TestNodeMatches(t *testing.T) {
+	def := &Node{Name: "greet", QualifiedName: "Greeter.greet#2"}
+
+	for selector, want := range map[string]bool{
+		"greet":           true,
+		"Greeter.greet":   true,
+		"Greeter.greet#2": true,
+		"Greeter.greet#1": false,
+		"Other.greet":     false,
+		"Greeter":         false,
+	} {
+		if got := def.Matches(selector); got != want {
+			t.Errorf("Matches(%q) = %v, expected %v", selector, got, want)
+		}
+	}
+}
+
+func TestStructuredOutlines(t *testing.T) {
+	tests := []struct {
+		path    string
+		text    string
+		expand  func(def *Node) bool
+		omitted []string
+	}{
+		{
+			path: "deployment.yaml",
+			text: `kind: Deployment
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+`,
+			expand: func(def *Node) bool {
+				return def.Depth < 2
+			},
+			omitted: []string{"spec.template"},
+		},
+		{
+			path: "deployment.yaml",
+			text: `kind: Deployment
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+`,
+			expand: func(def *Node) bool {
+				return def.Depth < 4
+			},
+			omitted: []string{"spec.template.spec.containers"},
+		},
+		{
+			path: "package.json",
+			text: `{
+  "name": "llmcat",
+  "scripts": {
+    "build": "go build"
+  },
+  "files": [
+    "a",
+    "b"
+  ]
+}
+`,
+			expand: func(def *Node) bool {
+				return def.Depth < 1
+			},
+			omitted: []string{"scripts", "files"},
+		},
+		{
+			path: "config.toml",
+			text: `title = "x"
+
+[server]
+host = "localhost"
+
+[server.tls]
+cert = "a"
+key = "b"
+`,
+			expand: func(def *Node) bool {
+				return def.Depth < 2
+			},
+			omitted: []string{"server.tls"},
+		},
+		{
+			path: "README.md",
+			text: `# Title
+
+Intro
+
+## Install
+
+go install
+
+### Deep
+
+deep text
+`,
+			omitted: []string{"Title", "Install", "Deep"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			proc, err := GetSymbols(context.TODO(), &SourceFile{
+				Path: tt.path,
+				Text: tt.text,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var omitted []string
+			for _, chunk := range proc.GetOutlineFunc(tt.expand) {
+				if chunk.ShouldOmit {
+					omitted = append(omitted, chunk.Name)
+				}
+			}
+
+			if fmt.Sprint(omitted) != fmt.Sprint(tt.omitted) {
+				t.Fatalf("omitted %v, expected %v", omitted, tt.omitted)
+			}
+		})
+	}
+}
+
+func TestLoadQueries(t *testing.T) {
+	embedded, err := tags.GetTagsQuery(language.Go)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		tags.SetTagsQuery(language.Go, embedded)
+	})
+
+	writeQuery := func(name, query string) string {
+		dir := t.TempDir()
+		err := os.WriteFile(filepath.Join(dir, name), []byte(query), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return dir
+	}
+
+	source := &SourceFile{
+		Path: "main.go",
+		Text: `package main
+
+const Version = "1"
+
+func main() {
+	return
+}
+`,
+	}
+
+	kinds := func() []string {
+		t.Helper()
+
+		proc, err := GetSymbols(context.TODO(), source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var kinds []string
+		for _, def := range proc.Definitions {
+			kinds = append(kinds, def.Name+":"+def.Kind)
+		}
+
+		return kinds
+	}
+
+	extends := writeQuery("go.scm", `; extends
+
+(const_spec
+  name: (identifier) @name.definition.constant) @definition.constant
+`)
+	if err := LoadQueries(extends); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(kinds()); got != "[Version:constant main:function]" {
+		t.Fatalf("expected the query to extend the embedded one, got %s", got)
+	}
+
+	replaces := writeQuery("golang.scm", `(const_spec
+  name: (identifier) @name.definition.constant) @definition.constant
+`)
+	if err := LoadQueries(replaces); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(kinds()); got != "[Version:constant]" {
+		t.Fatalf("expected the query to replace the embedded one, got %s", got)
+	}
+
+	invalid := []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{
+			name:  "go.scm",
+			query: "(function_declaration) @definition.function\n",
+			err:   "pattern 1 at line 1 has captures [definition.function]",
+		},
+		{
+			name:  "go.scm",
+			query: "(function_declaration\n  name: (identifier) @name.definition.function) @definition.function\n\n(funtion_declaration) @definition.function\n",
+			err:   "invalid node type 'funtion_declaration' at line 4",
+		},
+		{
+			name:  "cobol.scm",
+			query: "",
+			err:   `unknown language "cobol"`,
+		},
+		{
+			name:  "yaml.scm",
+			query: "(block_mapping_pair\n  key: (_) @name.definition.key) @definition.key\n",
+			err:   "yaml is outlined by its structure",
+		},
+	}
+
+	for _, tt := range invalid {
+		err := LoadQueries(writeQuery(tt.name, tt.query))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("expected error containing %q, got %v", tt.err, err)
+		}
+	}
+
+	// Invalid queries never get used
+	if got := fmt.Sprint(kinds()); got != "[Version:constant]" {
+		t.Fatalf("expected invalid queries to be ignored, got %s", got)
+	}
+}
+
+func TestGetSymbolsWarnings(t *testing.T) {
+	proc, err := GetSymbols(context.TODO(), &SourceFile{
+		Path: "broken.go",
+		Text: `package main
+
+func ok() {
+	return
+}
+
+func broken( {
+	return
+}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(proc.Warnings) != "[syntax error on line 7, so the outline may be unreliable]" {
+		t.Fatalf("unexpected warnings %v", proc.Warnings)
+	}
+
+	// Queries with patterns that don't capture a name and a symbol skip those
+	// matches, rather than failing the whole file
+	embedded, err := tags.GetTagsQuery(language.Go)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		tags.SetTagsQuery(language.Go, embedded)
+	})
+	tags.SetTagsQuery(language.Go, embedded+"\n(return_statement) @definition.return\n")
+
+	proc, err = GetSymbols(context.TODO(), &SourceFile{
+		Path: "main.go",
+		Text: `package main
+
+func main() {
+	return
+}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(proc.Definitions) != 1 || proc.Definitions[0].Name != "main" {
+		t.Fatalf("expected main to still be found, got %v", proc.Definitions)
+	}
+
+	if len(proc.Warnings) != 1 || !strings.Contains(proc.Warnings[0], "skipped 1 match(es) of tags query pattern") {
+		t.Fatalf("unexpected warnings %v", proc.Warnings)
+	}
+}
+
+func TestEmbeddedQueriesAreValid(t *testing.T) {
+	// Queries that are already known not to compile against their grammar
+	knownInvalid := map[language.Language]bool{
+		language.Ruby: true,
+	}
+
+	for _, lang := range []language.Language{
+		language.Python, language.Javascript, language.Typescript, language.Tsx,
+		language.Go, language.Rust, language.Cpp, language.C, language.Ruby,
+		language.Java, language.Php, language.Kotlin, language.Swift,
+		language.Scala, language.Lua, language.Bash,
+	} {
+		t.Run(string(lang), func(t *testing.T) {
+			query, err := tags.GetTagsQuery(lang)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ValidateQuery(lang, query)
+			if knownInvalid[lang] {
+				if err == nil {
+					t.Errorf("expected the %s query to be invalid; remove it from knownInvalid", lang)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestValidateQueryStructured(t *testing.T) {
+	for _, lang := range []language.Language{language.Yaml, language.Json, language.Toml, language.Markdown} {
+		err := ValidateQuery(lang, "")
+		if err == nil || !strings.Contains(err.Error(), "outlined by its structure") {
+			t.Errorf("expected a %s query to be rejected, got %v", lang, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package llmcat
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/everestmz/llmcat/treesym"
+	"github.com/everestmz/llmcat/treesym/language"
+)
+
+// ViewOptions picks the part of a file to view, either around a line or a
+// symbol
+type ViewOptions struct {
+	// Around is the line to center the view on, starting from 1
+	Around int `json:"around"`
+	// Symbol is the definition to view, by bare or qualified name. Every
+	// definition it matches is shown
+	Symbol string `json:"symbol"`
+	// Context is how many lines to show before and after the line or symbol
+	Context int `json:"context"`
+}
+
+// RenderView renders just part of a file, with the signatures of the
+// definitions it's inside as breadcrumbs above it, like `type Server struct`
+// and then `func (s *Server) Handle` for a line in Server.Handle
+func RenderView(filename, text string, view *ViewOptions, options *RenderFileOptions) (string, error) {
+	if (view.Around > 0) == (view.Symbol != "") {
+		return "", fmt.Errorf("expected a line or a symbol to view")
+	}
+
+	options.SetDefaults()
+	psf, err := parseFile(filename, text, options)
+	if err != nil && view.Symbol != "" {
+		return "", fmt.Errorf("can't find symbols in %s: %w", filename, err)
+	}
+
+	totalLines := strings.Count(text, "\n") + 1
+	var views []string
+	renderWindow := func(first, last int, focus *treesym.Node, withFocus bool) error {
+		first, last = max(first, 0), min(last, totalLines-1)
+
+		plan := options.Plan()
+		plan.Mode = RenderFull
+		plan.Page = 0
+		plan.StartLine = first + 1
+		plan.PageSize = last - first + 1
+		if psf != nil && focus != nil {
+			plan.Breadcrumbs = breadcrumbs(psf, focus, withFocus, first)
+		}
+
+		rendered, _, err := renderFile(filename, filename, text, plan)
+		if err != nil {
+			return err
+		}
+		views = append(views, rendered)
+
+		return nil
+	}
+
+	if view.Around > 0 {
+		if view.Around > totalLines {
+			return "", fmt.Errorf("line %d is past the end of %s, which has %s", view.Around, filename, countLines(totalLines))
+		}
+
+		row := view.Around - 1
+		var focus *treesym.Node
+		if psf != nil {
+			focus = innermostDefinition(psf, row)
+		}
+
+		err = renderWindow(row-view.Context, row+view.Context, focus, true)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.Join(views, "\n\n"), nil
+	}
+
+	for _, def := range psf.Definitions {
+		if !def.Matches(view.Symbol) {
+			continue
+		}
+
+		err = renderWindow(int(def.StartPoint.Row)-view.Context, int(def.EndPoint.Row)+view.Context, def, false)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(views) == 0 {
+		return "", fmt.Errorf("no symbol %q in %s%s", view.Symbol, filename, didYouMean(suggestSymbols(view.Symbol, psf)))
+	}
+
+	return strings.Join(views, "\n\n"), nil
+}
+
+// parseFile finds the symbols in a file, as it would be when rendering it
+func parseFile(filename, text string, options *RenderFileOptions) (*treesym.ProcessedSourceFile, error) {
+	detector, err := language.NewDetector(options.Languages)
+	if err != nil {
+		return nil, err
+	}
+
+	lang, err := detector.Detect(filename, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return treesym.GetSymbols(context.TODO(), &treesym.SourceFile{
+		Path:     filename,
+		Text:     text,
+		Language: lang,
+	})
+}
+
+// innermostDefinition is the smallest definition that row is part of, or nil
+// if it isn't in one
+func innermostDefinition(psf *treesym.ProcessedSourceFile, row int) *treesym.Node {
+	var innermost *treesym.Node
+	for _, def := range psf.Definitions {
+		if int(def.StartPoint.Row) > row || row > int(def.EndPoint.Row) {
+			continue
+		}
+
+		if innermost == nil || def.EndPoint.Row-def.StartPoint.Row < innermost.EndPoint.Row-innermost.StartPoint.Row {
+			innermost = def
+		}
+	}
+
+	return innermost
+}
+
+// breadcrumbs are the first rows of the definitions that def is declared in,
+// going by its qualified name, along with def itself if withSelf is set. Only
+// rows above first, where the view starts, are returned
+func breadcrumbs(psf *treesym.ProcessedSourceFile, def *treesym.Node, withSelf bool, first int) []int {
+	var rows []int
+	addRow := func(node *treesym.Node) {
+		row := int(node.StartPoint.Row)
+		if row < first && !slices.Contains(rows, row) {
+			rows = append(rows, row)
+		}
+	}
+
+	scope := strings.Split(def.QualifiedName, ".")
+	for i := 1; i < len(scope); i++ {
+		prefix := strings.Join(scope[:i], ".")
+		for _, other := range psf.Definitions {
+			if other != def && (other.QualifiedName == prefix || strings.HasPrefix(other.QualifiedName, prefix+"#")) {
+				addRow(other)
+				break
+			}
+		}
+	}
+
+	if withSelf {
+		addRow(def)
+	}
+
+	slices.Sort(rows)
+	return rows
+}
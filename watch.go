@@ -0,0 +1,314 @@
+package llmcat
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+type WatchOptions struct {
+	// Diff emits a line diff of the rendered output since the last emit,
+	// rather than the full output
+	Diff bool `json:"diff"`
+	// Debounce is how long to wait after a change before re-rendering, so
+	// that editors writing several files at once only trigger one refresh
+	Debounce time.Duration `json:"debounce"`
+	// DiffContext is the number of unchanged lines shown around each change
+	DiffContext int `json:"diff_context"`
+}
+
+func (wo *WatchOptions) SetDefaults() {
+	if wo.Debounce == 0 {
+		wo.Debounce = 200 * time.Millisecond
+	}
+
+	if wo.DiffContext == 0 {
+		wo.DiffContext = 3
+	}
+}
+
+type watchedFile struct {
+	text     string
+	rendered string
+}
+
+// WatchDirectory renders the directory dirName like RenderDirectory, then keeps watching the
+// selected files and calls emit every time the rendered output changes. Only
+// files whose contents changed are re-rendered. Once the first render is done,
+// files that can't be read or rendered are logged and left out until they
+// change again. It blocks until ctx is done, or emit returns an error
+func WatchDirectory(ctx context.Context, dirName string, options *RenderDirectoryOptions, watchOptions *WatchOptions, emit func(output string) error) error {
+	watchOptions.SetDefaults()
+
+	err := options.SetDefaults()
+	if err != nil {
+		return err
+	}
+
+	dirName, err = filepath.Abs(dirName)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(dirName)
+	if err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is a file, and only directories can be watched", dirName)
+	}
+
+	options, specWarnings := options.withResolvedSpec(dirName)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// fsnotify watches aren't recursive, so we watch every directory that
+	// contains a selected file, as well as the root for new files
+	watchedDirs := map[string]bool{}
+	watchDir := func(dir string) error {
+		if watchedDirs[dir] {
+			return nil
+		}
+
+		err := watcher.Add(dir)
+		if err != nil {
+			return fmt.Errorf("watching %s: %w", dir, err)
+		}
+		watchedDirs[dir] = true
+
+		return nil
+	}
+
+	// Directories created after we start aren't in the walk until they have a
+	// selected file in them, so we watch them, and everything already inside
+	// them, as soon as they appear
+	watchNewDir := func(dir string) error {
+		return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.IsDir() {
+				return nil
+			}
+
+			if options.ignoresDir(path) {
+				return filepath.SkipDir
+			}
+
+			return watchDir(path)
+		})
+	}
+
+	err = watchDir(dirName)
+	if err != nil {
+		return err
+	}
+
+	cache := map[string]*watchedFile{}
+	// Until the first render is done, errors are more likely to be a problem
+	// with the options than a file caught halfway through being written
+	watching := false
+
+	render := func() (string, error) {
+		var files []string
+		seen := map[string]bool{}
+
+		warnings, err := walkDirectory(dirName, options, func(path, relPath string) error {
+			text, err := os.ReadFile(path)
+			if err != nil && !watching {
+				return err
+			} else if err != nil {
+				// The file may be getting replaced, or may already be gone.
+				// Either way there'll be another event when it settles
+				log.Warn().Err(err).Str("file", relPath).Msg("Couldn't read file, leaving it out")
+				return nil
+			}
+
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+
+			err = watchDir(filepath.Dir(absPath))
+			if err != nil {
+				return err
+			}
+
+			seen[relPath] = true
+			cached, ok := cache[relPath]
+			if !ok || cached.text != string(text) {
+				log.Debug().Str("file", relPath).Msg("Re-rendering changed file")
+
+				// Warnings are already in the rendered file, and cached
+				// files wouldn't have theirs in a summary at the end
+				rendered, _, err := renderDirectoryFile(path, relPath, string(text), options)
+				if err != nil && !watching {
+					return err
+				} else if err != nil {
+					log.Warn().Err(err).Str("file", relPath).Msg("Couldn't render file, leaving it out")
+					delete(cache, relPath)
+					return nil
+				}
+
+				cached = &watchedFile{
+					text:     string(text),
+					rendered: rendered,
+				}
+				cache[relPath] = cached
+			}
+
+			files = append(files, cached.rendered)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		for relPath := range cache {
+			if !seen[relPath] {
+				delete(cache, relPath)
+			}
+		}
+
+		return joinRenderedFiles(files, append(slices.Clone(specWarnings), warnings...)), nil
+	}
+
+	lastOutput, err := render()
+	if err != nil {
+		return err
+	}
+
+	err = emit(lastOutput)
+	if err != nil {
+		return err
+	}
+	watching = true
+
+	debounce := time.NewTimer(0)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			// Like a queue overflow, which means we may have missed changes,
+			// so render again to catch up
+			log.Warn().Err(err).Msg("Error watching files")
+			debounce.Reset(watchOptions.Debounce)
+		case event := <-watcher.Events:
+			if event.Has(fsnotify.Chmod) {
+				continue
+			}
+
+			log.Debug().Str("file", event.Name).Str("op", event.Op.String()).Msg("File changed")
+
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					err = watchNewDir(event.Name)
+					if err != nil {
+						log.Warn().Err(err).Str("dir", event.Name).Msg("Couldn't watch new directory")
+					}
+				}
+			}
+
+			debounce.Reset(watchOptions.Debounce)
+		case <-debounce.C:
+			output, err := render()
+			if err != nil {
+				// Keep showing the last output until the next change
+				log.Warn().Err(err).Msg("Couldn't render directory")
+				continue
+			}
+
+			if output == lastOutput {
+				continue
+			}
+
+			if watchOptions.Diff {
+				err = emit(DiffLines(lastOutput, output, watchOptions.DiffContext))
+			} else {
+				err = emit(output)
+			}
+			if err != nil {
+				return err
+			}
+
+			lastOutput = output
+		}
+	}
+}
+
+// DiffLines returns a line diff between two renderings, with removed lines
+// prefixed by "-", added lines by "+", and unchanged lines by " ". Only
+// contextLines unchanged lines are kept around each change.
+func DiffLines(before, after string, contextLines int) string {
+	dmp := diffmatchpatch.New()
+
+	beforeChars, afterChars, lineArray := dmp.DiffLinesToChars(before+"\n", after+"\n")
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(beforeChars, afterChars, false), lineArray)
+
+	type diffLine struct {
+		op   diffmatchpatch.Operation
+		text string
+	}
+
+	var lines []diffLine
+	for _, diff := range diffs {
+		for _, line := range strings.Split(strings.TrimSuffix(diff.Text, "\n"), "\n") {
+			lines = append(lines, diffLine{op: diff.Type, text: line})
+		}
+	}
+
+	// Mark which unchanged lines are close enough to a change to be shown
+	show := make([]bool, len(lines))
+	for i, line := range lines {
+		if line.op == diffmatchpatch.DiffEqual {
+			continue
+		}
+
+		for j := max(i-contextLines, 0); j <= min(i+contextLines, len(lines)-1); j++ {
+			show[j] = true
+		}
+	}
+
+	var output []string
+	skipped := false
+	for i, line := range lines {
+		if !show[i] {
+			skipped = true
+			continue
+		}
+
+		if skipped && len(output) > 0 {
+			output = append(output, "...")
+		}
+		skipped = false
+
+		switch line.op {
+		case diffmatchpatch.DiffInsert:
+			output = append(output, "+"+line.text)
+		case diffmatchpatch.DiffDelete:
+			output = append(output, "-"+line.text)
+		default:
+			output = append(output, " "+line.text)
+		}
+	}
+
+	return strings.Join(output, "\n")
+}
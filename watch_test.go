@@ -0,0 +1,184 @@
+package llmcat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/everestmz/llmcat/ctxspec"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  string
+		after   string
+		context int
+		want    string
+	}{
+		{
+			name:   "unchanged",
+			before: "a\nb",
+			after:  "a\nb",
+			want:   "",
+		},
+		{
+			name:    "changed line",
+			before:  "a\nb\nc",
+			after:   "a\nB\nc",
+			context: 1,
+			want:    " a\n-b\n+B\n c",
+		},
+		{
+			name:    "added at the end",
+			before:  "a\nb",
+			after:   "a\nb\nc",
+			context: 1,
+			want:    " b\n+c",
+		},
+		{
+			name:    "unchanged lines between changes are collapsed",
+			before:  "a\n1\n2\n3\n4\n5\nb",
+			after:   "A\n1\n2\n3\n4\n5\nB",
+			context: 1,
+			want:    "-a\n+A\n 1\n...\n 5\n-b\n+B",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffLines(tt.before, tt.after, tt.context); got != tt.want {
+				t.Errorf("expected:\n%s\n\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+// watchTestDirectory starts watching dir, and returns a function that waits
+// for the next output that contains want
+func watchTestDirectory(t *testing.T, dir string, options *RenderDirectoryOptions) func(want string) string {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	outputs := make(chan string, 16)
+	stopped := make(chan struct{})
+	var watchErr error
+	go func() {
+		defer close(stopped)
+		watchErr = WatchDirectory(ctx, dir, options, &WatchOptions{Debounce: 20 * time.Millisecond}, func(output string) error {
+			select {
+			case outputs <- output:
+			case <-ctx.Done():
+			}
+			return nil
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+		if watchErr != nil {
+			t.Errorf("expected the watch to stop cleanly, got %v", watchErr)
+		}
+	})
+
+	return func(want string) string {
+		t.Helper()
+
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case output := <-outputs:
+				if strings.Contains(output, want) {
+					return output
+				}
+			case <-stopped:
+				t.Fatalf("watch stopped waiting for %q: %v", want, watchErr)
+			case <-timeout:
+				t.Fatalf("timed out waiting for output containing %q", want)
+			}
+		}
+	}
+}
+
+func TestWatchDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.go"), "package main\n\nfunc A() {}\n")
+
+	next := watchTestDirectory(t, dir, &RenderDirectoryOptions{
+		FileOptions: &RenderFileOptions{OutputMarkdown: true},
+	})
+
+	next("func A() {}")
+
+	writeTestFile(t, filepath.Join(dir, "a.go"), "package main\n\nfunc A2() {}\n")
+	next("func A2() {}")
+
+	// New directories are watched, even before they have any files in them
+	err := os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	writeTestFile(t, filepath.Join(dir, "sub", "b.go"), "package sub\n\nfunc B() {}\n")
+	output := next("func B() {}")
+	if !strings.Contains(output, "func A2() {}") {
+		t.Errorf("expected unchanged files to still be rendered, got:\n%s", output)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "sub", "b.go"), "package sub\n\nfunc B2() {}\n")
+	next("func B2() {}")
+}
+
+func TestWatchDirectoryErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.go")
+	writeTestFile(t, path, "package main\n\nfunc A() {}\n")
+
+	spec, err := ctxspec.ParseContextSpec("a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := watchTestDirectory(t, dir, &RenderDirectoryOptions{
+		FileOptions: &RenderFileOptions{OutputMarkdown: true},
+		ContextSpec: spec,
+	})
+
+	next("func A() {}")
+
+	// A symlink loop can't even be statted, which fails the whole render
+	err = os.Remove(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink("a.go", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// The watch carries on, and picks the file up once it's fixed
+	err = os.Remove(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, path, "package main\n\nfunc Fixed() {}\n")
+	next("func Fixed() {}")
+}
+
+func TestWatchDirectoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.go")
+	writeTestFile(t, path, "package main\n")
+
+	err := WatchDirectory(context.Background(), path, &RenderDirectoryOptions{FileOptions: &RenderFileOptions{}}, &WatchOptions{}, func(string) error {
+		t.Fatal("expected nothing to be rendered")
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "only directories can be watched") {
+		t.Fatalf("expected an error about watching a file, got %v", err)
+	}
+}